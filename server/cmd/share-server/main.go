@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -13,6 +14,8 @@ import (
 
 	"eendraadschema-share-server/internal/api"
 	"eendraadschema-share-server/internal/config"
+	"eendraadschema-share-server/internal/events"
+	"eendraadschema-share-server/internal/retention"
 	"eendraadschema-share-server/internal/store"
 	"eendraadschema-share-server/internal/web"
 )
@@ -35,12 +38,26 @@ func main() {
 	}
 	defer st.Close()
 
-	h, err := api.New(cfg, st)
+	// With Postgres, share events reach the clients of every instance; SQLite runs a single one.
+	var hub events.Hub = events.NewBroker()
+	if st.Driver() == "postgres" {
+		pg := events.NewPostgresHub(cfg.PostgresDSN)
+		go pg.Run(context.Background())
+		hub = pg
+	}
+
+	h, err := api.New(cfg, st, hub)
 	if err != nil {
 		log.Fatalf("failed to init api: %v", err)
 	}
 	apiHandler := h.Routes()
 
+	job, err := retention.New(cfg, st)
+	if err != nil {
+		log.Fatalf("invalid version retention policy: %v", err)
+	}
+	go job.Run(context.Background())
+
 	staticHandler, err := web.StaticHandler(cfg.StaticDir)
 	if err != nil {
 		log.Fatalf("failed to set up static handler: %v", err)
@@ -4,25 +4,34 @@ import (
 	"context"
 	"crypto/subtle"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"eendraadschema-share-server/internal/auth"
 	"eendraadschema-share-server/internal/config"
+	"eendraadschema-share-server/internal/events"
+	"eendraadschema-share-server/internal/schema"
 	"eendraadschema-share-server/internal/store"
 
 	"github.com/google/uuid"
 )
 
 type API struct {
-	cfg   config.Config
-	store *store.Store
-	oidc  *auth.OIDCVerifier
+	cfg    config.Config
+	store  *store.Store
+	oidc   *auth.OIDCVerifier
+	events events.Hub
+
+	ticketSweep   sweeper
+	presenceSweep sweeper
 }
 
-func New(cfg config.Config, st *store.Store) (*API, error) {
-	a := &API{cfg: cfg, store: st}
+func New(cfg config.Config, st *store.Store, hub events.Hub) (*API, error) {
+	a := &API{cfg: cfg, store: st, events: hub}
 	if strings.TrimSpace(cfg.OIDCIssuerURL) != "" || strings.TrimSpace(cfg.OIDCClientID) != "" {
 		v, err := auth.NewOIDCVerifier(context.Background(), cfg)
 		if err != nil {
@@ -106,6 +115,9 @@ type createShareRequest struct {
 	Password string `json:"password"`
 	BaseURL  string `json:"baseUrl"`
 	TeamID   string `json:"teamId"`
+	// Optional version message, and whether the schema was imported from a file rather than edited.
+	Message string `json:"message"`
+	Import  bool   `json:"import"`
 }
 
 type createShareResponse struct {
@@ -117,6 +129,15 @@ type updateShareRequest struct {
 	Schema   string `json:"schema"`
 	Name     *string `json:"name"`
 	Password string `json:"password"`
+	Message  string `json:"message"`
+	Import   bool   `json:"import"`
+	// Identifies the editor for edit leases when OIDC is not used.
+	ClientID string `json:"clientId"`
+}
+
+type restoreVersionRequest struct {
+	Message  string `json:"message"`
+	ClientID string `json:"clientId"`
 }
 
 type getShareResponse struct {
@@ -124,6 +145,9 @@ type getShareResponse struct {
 	Name      string `json:"name,omitempty"`
 	Schema    string `json:"schema"`
 	UpdatedAt string `json:"updatedAt"`
+	// VersionID is the current version, also sent as the ETag to use in If-Match.
+	VersionID string         `json:"versionId"`
+	Review    reviewResponse `json:"review"`
 }
 
 func (a *API) Routes() http.Handler {
@@ -131,6 +155,7 @@ func (a *API) Routes() http.Handler {
 	mux.HandleFunc("/api/shares", a.handleShares)
 	mux.HandleFunc("/api/shares/mine", a.handleMyShares)
 	mux.HandleFunc("/api/shares/", a.handleShareByID)
+	mux.HandleFunc("/api/lint", a.handleLint)
 	mux.HandleFunc("/api/teams", a.handleTeams)
 	mux.HandleFunc("/api/teams/", a.handleTeamByID)
 	mux.HandleFunc("/api/invites/accept", a.handleAcceptInvite)
@@ -139,6 +164,7 @@ func (a *API) Routes() http.Handler {
 	mux.HandleFunc("/api/admin/users/", a.handleAdminUserBySub)
 	mux.HandleFunc("/api/admin/shares", a.handleAdminShares)
 	mux.HandleFunc("/api/admin/shares/", a.handleAdminShareByID)
+	mux.HandleFunc("/api/admin/schemas/upgrade", a.handleAdminUpgradeSchemas)
 	mux.HandleFunc("/api/healthz", a.handleHealthz)
 	return a.withMiddleware(mux)
 }
@@ -176,8 +202,8 @@ func (a *API) withMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Preflight
 		if r.Method == http.MethodOptions {
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match")
 			if a.cfg.AllowedOrigin != "" {
 				w.Header().Set("Access-Control-Allow-Origin", a.cfg.AllowedOrigin)
 				w.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -232,10 +258,14 @@ func (a *API) handleShares(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "missing_schema", "schema is required")
 		return
 	}
-	if !strings.HasPrefix(req.Schema, "EDS") && !strings.HasPrefix(req.Schema, "TXT") {
-		writeError(w, http.StatusBadRequest, "invalid_schema", "schema must start with EDS... or TXT...")
+	if !checkVersionMessage(w, req.Message) {
+		return
+	}
+	normalized, ok := a.prepareSchema(w, req.Schema)
+	if !ok {
 		return
 	}
+	req.Schema = normalized
 
 	now := time.Now().UTC()
 
@@ -274,15 +304,11 @@ func (a *API) handleShares(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := a.store.CreateShare(r.Context(), id, name, req.Schema, ownerSub, teamID, now); err != nil {
+	version := newShareVersion(req.Schema, actorSub, store.ShareVersionInfo{Kind: writeKind(req.Import, store.VersionKindCreate), Message: req.Message})
+	if err := a.store.CreateShare(r.Context(), id, name, req.Schema, ownerSub, teamID, version, now); err != nil {
 		writeError(w, http.StatusInternalServerError, "db_insert_failed", "could not store share")
 		return
 	}
-	// Store first version (best-effort; don't fail the main request on versioning issues).
-	_ = a.store.AddShareVersion(r.Context(), uuid.NewString(), id, req.Schema, actorSub, now)
-	if a.cfg.ShareVersionsMax > 0 {
-		_ = a.store.PruneShareVersions(r.Context(), id, a.cfg.ShareVersionsMax)
-	}
 
 	// Create a session for the creator so subsequent calls don't require the password again.
 	// Only relevant for legacy password mode.
@@ -322,6 +348,58 @@ func (a *API) handleShareByID(w http.ResponseWriter, r *http.Request) {
 		a.handleShareVersions(w, r, id, parts[2:])
 		return
 	}
+	if len(parts) >= 2 && parts[1] == "comments" {
+		a.handleShareComments(w, r, id, parts[2:])
+		return
+	}
+	if len(parts) >= 2 && parts[1] == "tags" {
+		a.handleShareTags(w, r, id, parts[2:])
+		return
+	}
+	if len(parts) == 2 && parts[1] == "review" {
+		a.handleShareReview(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "lease" {
+		a.handleShareLease(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "presence" {
+		a.handleSharePresence(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "ticket" {
+		a.handleShareTicket(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "events" {
+		a.handleShareEvents(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "bom" {
+		a.handleShareBOM(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "cables" {
+		a.handleShareCables(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "lint" {
+		a.handleShareLint(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "export" {
+		a.handleShareExport(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "report.pdf" {
+		a.handleShareReport(w, r, id)
+		return
+	}
+	if len(parts) == 2 && (parts[1] == "thumbnail.svg" || parts[1] == "thumbnail.png") {
+		a.handleShareThumbnail(w, r, id, strings.TrimPrefix(parts[1], "thumbnail."))
+		return
+	}
 	if len(parts) != 1 {
 		writeError(w, http.StatusNotFound, "not_found", "not found")
 		return
@@ -332,6 +410,8 @@ func (a *API) handleShareByID(w http.ResponseWriter, r *http.Request) {
 		a.handleGetShare(w, r, id)
 	case http.MethodPut:
 		a.handleUpdateShare(w, r, id)
+	case http.MethodPatch:
+		a.handlePatchShare(w, r, id)
 	case http.MethodDelete:
 		a.handleDeleteShare(w, r, id)
 	default:
@@ -369,6 +449,7 @@ func (a *API) handleDeleteShare(w http.ResponseWriter, r *http.Request, id strin
 		writeError(w, http.StatusInternalServerError, "db_delete_failed", "could not delete share")
 		return
 	}
+	a.publishShareEvent(events.Event{Type: events.Delete, ShareID: id, ActorSub: u.Sub, At: time.Now().UTC()})
 	writeJSON(w, http.StatusOK, map[string]any{"id": id, "deleted": true})
 }
 
@@ -388,18 +469,13 @@ func (a *API) canAccessShare(w http.ResponseWriter, r *http.Request, shareID str
 			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
 			return "", false
 		}
-		if strings.TrimSpace(sh.OwnerSub) != "" && sh.OwnerSub == u.Sub {
-			return u.Sub, true
+		okMember, err := a.isShareMember(r.Context(), sh, u.Sub)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read team membership")
+			return "", false
 		}
-		if sh.TeamID.Valid {
-			_, okMember, err := a.store.IsTeamMember(r.Context(), sh.TeamID.String, u.Sub)
-			if err != nil {
-				writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read team membership")
-				return "", false
-			}
-			if okMember {
-				return u.Sub, true
-			}
+		if okMember {
+			return u.Sub, true
 		}
 		writeError(w, http.StatusForbidden, "forbidden", "not allowed")
 		return "", false
@@ -413,9 +489,20 @@ func (a *API) canAccessShare(w http.ResponseWriter, r *http.Request, shareID str
 	return "", true
 }
 
+// isShareMember reports whether the user owns the share or is in its team.
+func (a *API) isShareMember(ctx context.Context, sh store.Share, sub string) (bool, error) {
+	if strings.TrimSpace(sh.OwnerSub) != "" && sh.OwnerSub == sub {
+		return true, nil
+	}
+	if !sh.TeamID.Valid {
+		return false, nil
+	}
+	_, ok, err := a.store.IsTeamMember(ctx, sh.TeamID.String, sub)
+	return ok, err
+}
+
 func (a *API) handleShareVersions(w http.ResponseWriter, r *http.Request, shareID string, rest []string) {
 	actorSub, ok := a.canAccessShare(w, r, shareID)
-	_ = actorSub
 	if !ok {
 		return
 	}
@@ -431,12 +518,26 @@ func (a *API) handleShareVersions(w http.ResponseWriter, r *http.Request, shareI
 			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not list share versions")
 			return
 		}
+		tags, err := a.store.ListShareVersionTags(r.Context(), shareID, "")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not list tags")
+			return
+		}
+		tagsByVersion := map[string][]string{}
+		for _, t := range tags {
+			tagsByVersion[t.VersionID] = append(tagsByVersion[t.VersionID], t.Name)
+		}
 		out := make([]map[string]any, 0, len(items))
 		for _, it := range items {
 			out = append(out, map[string]any{
-				"id": it.ID,
-				"createdAt": it.CreatedAt.UTC().Format(time.RFC3339),
-				"createdBySub": it.CreatedBySub,
+				"id":              it.ID,
+				"createdAt":       it.CreatedAt.UTC().Format(time.RFC3339),
+				"createdBySub":    it.CreatedBySub,
+				"summary":         it.Summary,
+				"message":         it.Message,
+				"parentVersionId": it.ParentVersionID,
+				"kind":            it.Kind,
+				"tags":            append([]string{}, tagsByVersion[it.ID]...),
 			})
 		}
 		writeJSON(w, http.StatusOK, out)
@@ -468,12 +569,41 @@ func (a *API) handleShareVersions(w http.ResponseWriter, r *http.Request, shareI
 		return
 	}
 
+	// /api/shares/{id}/versions/{ver}/tags[/{name}]
+	if (len(rest) == 2 || len(rest) == 3) && rest[1] == "tags" {
+		a.handleShareVersionTags(w, r, shareID, verID, actorSub, rest[2:])
+		return
+	}
+
+	// /api/shares/{id}/versions/{ver}/diff/{other}
+	if len(rest) == 3 && rest[1] == "diff" {
+		otherID := strings.TrimSpace(rest[2])
+		if otherID == "" {
+			writeError(w, http.StatusNotFound, "not_found", "not found")
+			return
+		}
+		a.handleShareVersionDiff(w, r, shareID, verID, otherID)
+		return
+	}
+
 	// /api/shares/{id}/versions/{ver}/restore
 	if len(rest) == 2 && rest[1] == "restore" {
 		if r.Method != http.MethodPost {
 			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
 			return
 		}
+		// The body is optional.
+		var req restoreVersionRequest
+		r.Body = http.MaxBytesReader(w, r.Body, a.cfg.MaxBodyBytes)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, "bad_json", "invalid json")
+			return
+		}
+		if !checkVersionMessage(w, req.Message) {
+			return
+		}
 		schema, err := a.store.GetShareVersion(r.Context(), shareID, verID)
 		if err != nil {
 			if err == store.ErrNotFound {
@@ -483,8 +613,31 @@ func (a *API) handleShareVersions(w http.ResponseWriter, r *http.Request, shareI
 			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share version")
 			return
 		}
+		if !a.checkStrictSchema(w, schema) {
+			return
+		}
 		now := time.Now().UTC()
-		if err := a.store.UpdateShare(r.Context(), shareID, schema, now); err != nil {
+		if !a.checkShareLease(w, r, shareID, req.ClientID, now) {
+			return
+		}
+		// If-Match is honoured when sent, but not required for restores.
+		var ifMatch *string
+		if r.Header.Get("If-Match") != "" {
+			head, err := a.store.GetShareHeadVersion(r.Context(), shareID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+				return
+			}
+			if ifMatch, ok = a.ifMatchVersion(w, r, shareID, head); !ok {
+				return
+			}
+		}
+		version := newShareVersion(schema, actorSub, store.ShareVersionInfo{Kind: store.VersionKindRestore, Message: req.Message, ParentVersionID: verID})
+		if err := a.store.UpdateShareFields(r.Context(), shareID, &schema, nil, ifMatch, version, now); err != nil {
+			if err == store.ErrVersionMismatch {
+				a.writeVersionMismatch(w, r, shareID)
+				return
+			}
 			if err == store.ErrNotFound {
 				writeError(w, http.StatusNotFound, "not_found", "share not found")
 				return
@@ -492,12 +645,8 @@ func (a *API) handleShareVersions(w http.ResponseWriter, r *http.Request, shareI
 			writeError(w, http.StatusInternalServerError, "db_update_failed", "could not update share")
 			return
 		}
-		// Add a new version entry for the restore action (best-effort)
-		actorSub, _ := a.canAccessShare(w, r, shareID)
-		_ = a.store.AddShareVersion(r.Context(), uuid.NewString(), shareID, schema, actorSub, now)
-		if a.cfg.ShareVersionsMax > 0 {
-			_ = a.store.PruneShareVersions(r.Context(), shareID, a.cfg.ShareVersionsMax)
-		}
+		head := a.setVersionETag(w, r, shareID)
+		a.publishShareEvent(events.Event{Type: events.Restore, ShareID: shareID, VersionID: head, ActorSub: actorSub, At: now})
 		writeJSON(w, http.StatusOK, map[string]any{"id": shareID, "restored": true, "versionId": verID})
 		return
 	}
@@ -516,7 +665,8 @@ func (a *API) handleGetShare(w http.ResponseWriter, r *http.Request, id string)
 		return
 	}
 	// Public endpoint: anyone with the UUID can fetch the schema.
-	writeJSON(w, http.StatusOK, getShareResponse{ID: sh.ID, Name: strings.TrimSpace(sh.Name), Schema: sh.Schema, UpdatedAt: sh.UpdatedAt.Format(time.RFC3339)})
+	w.Header().Set("ETag", versionETag(sh.HeadVersionID))
+	writeJSON(w, http.StatusOK, getShareResponse{ID: sh.ID, Name: strings.TrimSpace(sh.Name), Schema: sh.Schema, UpdatedAt: sh.UpdatedAt.Format(time.RFC3339), VersionID: sh.HeadVersionID, Review: reviewJSON(sh.Review)})
 }
 
 func (a *API) handleUpdateShare(w http.ResponseWriter, r *http.Request, id string) {
@@ -528,13 +678,15 @@ func (a *API) handleUpdateShare(w http.ResponseWriter, r *http.Request, id strin
 		writeError(w, http.StatusBadRequest, "bad_json", "invalid json")
 		return
 	}
+	if !checkVersionMessage(w, req.Message) {
+		return
+	}
 	var schemaPtr *string
 	if req.Schema != "" {
-		if !strings.HasPrefix(req.Schema, "EDS") && !strings.HasPrefix(req.Schema, "TXT") {
-			writeError(w, http.StatusBadRequest, "invalid_schema", "schema must start with EDS... or TXT...")
+		s, ok := a.prepareSchema(w, req.Schema)
+		if !ok {
 			return
 		}
-		s := req.Schema
 		schemaPtr = &s
 	}
 	if schemaPtr == nil && req.Name == nil {
@@ -553,42 +705,51 @@ func (a *API) handleUpdateShare(w http.ResponseWriter, r *http.Request, id strin
 	}
 
 	now := time.Now().UTC()
+	if !a.authorizeShareUpdate(w, r, sh, req.Password, now) {
+		return
+	}
+	if !a.checkShareLease(w, r, id, req.ClientID, now) {
+		return
+	}
+	ifMatch, ok := a.ifMatchVersion(w, r, id, sh.HeadVersionID)
+	if !ok {
+		return
+	}
+	a.commitShareUpdate(w, r, id, schemaPtr, ifMatch, req, now)
+}
+
+// authorizeShareUpdate checks that the caller may change the share: its owner or a member of its
+// team with OIDC, otherwise anyone with the server password (or a session).
+func (a *API) authorizeShareUpdate(w http.ResponseWriter, r *http.Request, sh store.Share, password string, now time.Time) bool {
 	if a.oidcEnabled() {
 		u, ok := a.requireUser(w, r)
 		if !ok {
-			return
+			return false
 		}
 		if strings.TrimSpace(sh.OwnerSub) != "" && sh.OwnerSub == u.Sub {
-			// ok
-		} else if sh.TeamID.Valid {
-			_, okMember, err := a.store.IsTeamMember(r.Context(), sh.TeamID.String, u.Sub)
-			if err != nil {
-				writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read team membership")
-				return
-			}
-			if !okMember {
-				writeError(w, http.StatusForbidden, "forbidden", "not allowed")
-				return
-			}
-		} else {
+			return true
+		}
+		if !sh.TeamID.Valid {
 			writeError(w, http.StatusForbidden, "forbidden", "not allowed")
-			return
+			return false
 		}
-	} else {
-		if !a.requireAuth(w, r, now, req.Password, id) {
-			return
+		_, okMember, err := a.store.IsTeamMember(r.Context(), sh.TeamID.String, u.Sub)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read team membership")
+			return false
 		}
-	}
-
-	if err := a.store.UpdateShareFields(r.Context(), id, schemaPtr, req.Name, now); err != nil {
-		if err == store.ErrNotFound {
-			writeError(w, http.StatusNotFound, "not_found", "share not found")
-			return
+		if !okMember {
+			writeError(w, http.StatusForbidden, "forbidden", "not allowed")
+			return false
 		}
-		writeError(w, http.StatusInternalServerError, "db_update_failed", "could not update share")
-		return
+		return true
 	}
-	// Add version entry (best-effort)
+	return a.requireAuth(w, r, now, password, sh.ID)
+}
+
+// commitShareUpdate stores an authorized update of the share's schema and/or name, conditional on
+// ifMatch, and records the new version. A schema update that lost a race is merged when it can be.
+func (a *API) commitShareUpdate(w http.ResponseWriter, r *http.Request, id string, schemaPtr *string, ifMatch *string, req updateShareRequest, now time.Time) {
 	actorSub := ""
 	if a.oidcEnabled() {
 		u, ok := a.requireUser(w, r)
@@ -596,13 +757,33 @@ func (a *API) handleUpdateShare(w http.ResponseWriter, r *http.Request, id strin
 			actorSub = u.Sub
 		}
 	}
+	var version store.ShareVersionInfo
 	if schemaPtr != nil {
-		_ = a.store.AddShareVersion(r.Context(), uuid.NewString(), id, *schemaPtr, actorSub, now)
+		version = newShareVersion(*schemaPtr, actorSub, store.ShareVersionInfo{Kind: writeKind(req.Import, store.VersionKindUpdate), Message: req.Message})
 	}
-	if a.cfg.ShareVersionsMax > 0 {
-		_ = a.store.PruneShareVersions(r.Context(), id, a.cfg.ShareVersionsMax)
+	if err := a.store.UpdateShareFields(r.Context(), id, schemaPtr, req.Name, ifMatch, version, now); err != nil {
+		if err == store.ErrVersionMismatch {
+			if schemaPtr != nil && ifMatch != nil {
+				a.mergeShareUpdate(w, r, id, *ifMatch, *schemaPtr, req, now)
+				return
+			}
+			a.writeVersionMismatch(w, r, id)
+			return
+		}
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "share not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "db_update_failed", "could not update share")
+		return
+	}
+	head := a.setVersionETag(w, r, id)
+	if schemaPtr != nil {
+		a.publishShareEvent(events.Event{Type: events.Update, ShareID: id, VersionID: head, ActorSub: actorSub, At: now})
+	}
+	if req.Name != nil {
+		a.publishShareEvent(events.Event{Type: events.Rename, ShareID: id, VersionID: head, ActorSub: actorSub, Name: strings.TrimSpace(*req.Name), At: now})
 	}
-
 	writeJSON(w, http.StatusOK, map[string]any{"id": id, "updated": true})
 }
 
@@ -703,7 +884,7 @@ func (a *API) handleAdminShares(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
 		return
 	}
-	_, ok := a.requireAdminUser(w, r)
+	u, ok := a.requireAdminUser(w, r)
 	if !ok {
 		return
 	}
@@ -712,6 +893,10 @@ func (a *API) handleAdminShares(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not list shares")
 		return
 	}
+	ticket, ok := a.listTicket(w, r, u.Sub, len(items))
+	if !ok {
+		return
+	}
 	ownerSubs := make([]string, 0, len(items))
 	for _, it := range items {
 		if strings.TrimSpace(it.OwnerSub) != "" {
@@ -734,14 +919,16 @@ func (a *API) handleAdminShares(w http.ResponseWriter, r *http.Request) {
 			ownerEmail = strings.TrimSpace(o.Email)
 		}
 		out = append(out, map[string]any{
-			"id":        it.ID,
-			"name":      strings.TrimSpace(it.Name),
-			"ownerSub":  it.OwnerSub,
-			"ownerName": ownerName,
-			"ownerEmail": ownerEmail,
-			"teamId":    tid,
-			"createdAt": it.CreatedAt.UTC().Format(time.RFC3339),
-			"updatedAt": it.UpdatedAt.UTC().Format(time.RFC3339),
+			"id":           it.ID,
+			"name":         strings.TrimSpace(it.Name),
+			"ownerSub":     it.OwnerSub,
+			"ownerName":    ownerName,
+			"ownerEmail":   ownerEmail,
+			"teamId":       tid,
+			"createdAt":    it.CreatedAt.UTC().Format(time.RFC3339),
+			"updatedAt":    it.UpdatedAt.UTC().Format(time.RFC3339),
+			"thumbnailUrl": thumbnailURL(it.ID, ticket),
+			"reviewStatus": it.ReviewStatus,
 		})
 	}
 	writeJSON(w, http.StatusOK, out)
@@ -772,7 +959,45 @@ func (a *API) handleAdminShareByID(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
 		return
 	}
-	writeJSON(w, http.StatusOK, getShareResponse{ID: sh.ID, Name: strings.TrimSpace(sh.Name), Schema: sh.Schema, UpdatedAt: sh.UpdatedAt.UTC().Format(time.RFC3339)})
+	writeJSON(w, http.StatusOK, getShareResponse{ID: sh.ID, Name: strings.TrimSpace(sh.Name), Schema: sh.Schema, UpdatedAt: sh.UpdatedAt.UTC().Format(time.RFC3339), VersionID: sh.HeadVersionID, Review: reviewJSON(sh.Review)})
+}
+
+// handleAdminUpgradeSchemas upgrades every stored schema that is not yet in the current schema
+// format; shares and versions count the rows using an upgraded schema. Pass ?dryRun=true to only
+// report what would change.
+func (a *API) handleAdminUpgradeSchemas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	_, ok := a.requireAdminUser(w, r)
+	if !ok {
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	current := []string{
+		fmt.Sprintf("%s%03d", schema.FormatEDS, schema.CurrentVersion),
+		fmt.Sprintf("%s%03d", schema.FormatTXT, schema.CurrentVersion),
+	}
+	res, err := a.store.RewriteSchemas(r.Context(), current, dryRun, schema.Upgrade)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db_update_failed", "could not upgrade schemas")
+		return
+	}
+	failures := make([]map[string]any, 0, len(res.Failures))
+	for _, f := range res.Failures {
+		failures = append(failures, map[string]any{"table": f.Table, "id": f.ID, "error": f.Err.Error()})
+	}
+	stats := func(st store.SchemaRewriteStats) map[string]any {
+		return map[string]any{"scanned": st.Scanned, "upgraded": st.Rewritten, "failed": st.Failed}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"dryRun":   dryRun,
+		"schemas":  stats(res.Blobs),
+		"shares":   res.Shares,
+		"versions": res.Versions,
+		"failures": failures,
+	})
 }
 
 func (a *API) handleMyShares(w http.ResponseWriter, r *http.Request) {
@@ -789,6 +1014,10 @@ func (a *API) handleMyShares(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not list shares")
 		return
 	}
+	ticket, ok := a.listTicket(w, r, u.Sub, len(items))
+	if !ok {
+		return
+	}
 	out := make([]map[string]any, 0, len(items))
 	for _, it := range items {
 		var tid any
@@ -798,11 +1027,13 @@ func (a *API) handleMyShares(w http.ResponseWriter, r *http.Request) {
 			tid = nil
 		}
 		out = append(out, map[string]any{
-			"id":        it.ID,
-			"name":      strings.TrimSpace(it.Name),
-			"teamId":    tid,
-			"createdAt": it.CreatedAt.UTC().Format(time.RFC3339),
-			"updatedAt": it.UpdatedAt.UTC().Format(time.RFC3339),
+			"id":           it.ID,
+			"name":         strings.TrimSpace(it.Name),
+			"teamId":       tid,
+			"createdAt":    it.CreatedAt.UTC().Format(time.RFC3339),
+			"updatedAt":    it.UpdatedAt.UTC().Format(time.RFC3339),
+			"thumbnailUrl": thumbnailURL(it.ID, ticket),
+			"reviewStatus": it.ReviewStatus,
 		})
 	}
 	writeJSON(w, http.StatusOK, out)
@@ -827,7 +1058,7 @@ func (a *API) handleTeams(w http.ResponseWriter, r *http.Request) {
 		}
 		out := make([]map[string]any, 0, len(teams))
 		for _, t := range teams {
-			out = append(out, map[string]any{"id": t.ID, "name": t.Name, "role": t.Role})
+			out = append(out, map[string]any{"id": t.ID, "name": t.Name, "role": t.Role, "retentionPolicy": t.RetentionPolicy})
 		}
 		writeJSON(w, http.StatusOK, out)
 	case http.MethodPost:
@@ -861,7 +1092,7 @@ type createInviteRequest struct {
 }
 
 func (a *API) handleTeamByID(w http.ResponseWriter, r *http.Request) {
-	// Supports: POST /api/teams/{id}/invites
+	// Supports: POST /api/teams/{id}/invites, GET/PUT /api/teams/{id}/retention
 	path := strings.TrimPrefix(r.URL.Path, "/api/teams/")
 	path = strings.TrimSpace(path)
 	if path == "" {
@@ -874,6 +1105,10 @@ func (a *API) handleTeamByID(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "not_found", "not found")
 		return
 	}
+	if len(parts) == 2 && parts[1] == "retention" {
+		a.handleTeamRetention(w, r, teamID)
+		return
+	}
 	if len(parts) != 2 || parts[1] != "invites" {
 		writeError(w, http.StatusNotFound, "not_found", "not found")
 		return
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+
+	"eendraadschema-share-server/internal/bom"
+)
+
+// handleShareBOM serves GET /api/shares/{id}/bom as JSON, or as CSV with ?format=csv.
+func (a *API) handleShareBOM(w http.ResponseWriter, r *http.Request, shareID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	if _, ok := a.canAccessShare(w, r, shareID); !ok {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeError(w, http.StatusBadRequest, "bad_format", "format must be json or csv")
+		return
+	}
+	_, doc, ok := a.readShareDocument(w, r, shareID)
+	if !ok {
+		return
+	}
+	b := bom.Compute(doc)
+
+	if format != "csv" {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"shareId": shareID,
+			"totals":  map[string]any{"cables": b.Cables, "devices": b.Devices},
+			"kringen": b.Kringen,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="bom-`+shareID+`.csv"`)
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"kring", "label", "unit", "quantity", "key"})
+	writeLines := func(kring string, lines []bom.Line) {
+		for _, l := range lines {
+			_ = cw.Write([]string{kring, l.Label, string(l.Unit), strconv.FormatFloat(l.Quantity, 'f', -1, 64), l.Key})
+		}
+	}
+	// Totals come first with an empty kring column.
+	writeLines("", b.Cables)
+	writeLines("", b.Devices)
+	for _, k := range b.Kringen {
+		writeLines(k.Kring, k.Cables)
+		writeLines(k.Kring, k.Devices)
+	}
+	cw.Flush()
+}
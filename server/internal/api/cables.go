@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+
+	"eendraadschema-share-server/internal/sitplan"
+)
+
+// handleShareCables serves GET /api/shares/{id}/cables: cable lengths per run, per kring and per floor.
+func (a *API) handleShareCables(w http.ResponseWriter, r *http.Request, shareID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	if _, ok := a.canAccessShare(w, r, shareID); !ok {
+		return
+	}
+	_, doc, ok := a.readShareDocument(w, r, shareID)
+	if !ok {
+		return
+	}
+	rep := sitplan.Compute(doc)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"shareId": shareID,
+		"totals":  rep.Summary,
+		"kringen": rep.Kringen,
+		"floors":  rep.Floors,
+	})
+}
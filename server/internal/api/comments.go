@@ -0,0 +1,246 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"eendraadschema-share-server/internal/schema"
+	"eendraadschema-share-server/internal/store"
+)
+
+// maxCommentBody is the longest comment accepted, in characters.
+const maxCommentBody = 4000
+
+type createCommentRequest struct {
+	// ItemID and VersionID anchor a new thread; VersionID defaults to the current version.
+	// Replies only need a body.
+	ItemID    int    `json:"itemId"`
+	VersionID string `json:"versionId"`
+	Body      string `json:"body"`
+	// Name is shown as the author when OIDC is not used.
+	Name string `json:"name"`
+}
+
+func commentJSON(c store.ShareComment) map[string]any {
+	return map[string]any{
+		"id":         c.ID,
+		"versionId":  c.VersionID,
+		"authorSub":  c.AuthorSub,
+		"authorName": c.AuthorName,
+		"body":       c.Body,
+		"createdAt":  c.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// commentThreads groups comments (oldest first) into threads with their replies.
+func commentThreads(comments []store.ShareComment) []map[string]any {
+	threads := []map[string]any{}
+	byID := map[string]map[string]any{}
+	for _, c := range comments {
+		if !c.IsThread() {
+			continue
+		}
+		t := commentJSON(c)
+		t["itemId"] = c.ItemID
+		t["resolved"] = c.ResolvedAt != nil
+		if c.ResolvedAt != nil {
+			t["resolvedAt"] = c.ResolvedAt.UTC().Format(time.RFC3339)
+			t["resolvedBySub"] = c.ResolvedBySub
+		}
+		t["replies"] = []map[string]any{}
+		threads = append(threads, t)
+		byID[c.ID] = t
+	}
+	for _, c := range comments {
+		if t, ok := byID[c.ThreadID]; ok && !c.IsThread() {
+			t["replies"] = append(t["replies"].([]map[string]any), commentJSON(c))
+		}
+	}
+	return threads
+}
+
+// handleShareComments serves /api/shares/{id}/comments:
+//   - GET lists the threads (?itemId= and ?resolved=true|false filter them), POST starts one
+//   - GET .../{thread} returns one thread, POST .../{thread}/replies adds a reply
+//   - POST .../{thread}/resolve and .../{thread}/unresolve change its state
+func (a *API) handleShareComments(w http.ResponseWriter, r *http.Request, shareID string, rest []string) {
+	actorSub, ok := a.canAccessShare(w, r, shareID)
+	if !ok {
+		return
+	}
+
+	if len(rest) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			a.listCommentThreads(w, r, shareID)
+		case http.MethodPost:
+			a.addComment(w, r, shareID, "", actorSub)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		}
+		return
+	}
+
+	threadID := strings.TrimSpace(rest[0])
+	if len(rest) == 1 {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+			return
+		}
+		a.writeCommentThread(w, r, shareID, threadID, http.StatusOK)
+		return
+	}
+	if len(rest) != 2 {
+		writeError(w, http.StatusNotFound, "not_found", "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	switch rest[1] {
+	case "replies":
+		a.addComment(w, r, shareID, threadID, actorSub)
+	case "resolve", "unresolve":
+		if err := a.store.SetShareCommentThreadResolved(r.Context(), shareID, threadID, rest[1] == "resolve", actorSub, time.Now().UTC()); err != nil {
+			if err == store.ErrNotFound {
+				writeError(w, http.StatusNotFound, "not_found", "thread not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "db_update_failed", "could not update thread")
+			return
+		}
+		a.writeCommentThread(w, r, shareID, threadID, http.StatusOK)
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "not found")
+	}
+}
+
+func (a *API) listCommentThreads(w http.ResponseWriter, r *http.Request, shareID string) {
+	q := r.URL.Query()
+	itemID := 0
+	if v := strings.TrimSpace(q.Get("itemId")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "bad_item_id", "itemId must be a positive number")
+			return
+		}
+		itemID = n
+	}
+	resolved := strings.TrimSpace(q.Get("resolved"))
+	if resolved != "" && resolved != "true" && resolved != "false" {
+		writeError(w, http.StatusBadRequest, "bad_resolved", "resolved must be true or false")
+		return
+	}
+
+	comments, err := a.store.ListShareComments(r.Context(), shareID, "")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not list comments")
+		return
+	}
+	out := make([]map[string]any, 0)
+	for _, t := range commentThreads(comments) {
+		if itemID != 0 && t["itemId"] != itemID {
+			continue
+		}
+		if resolved != "" && t["resolved"] != (resolved == "true") {
+			continue
+		}
+		out = append(out, t)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a *API) writeCommentThread(w http.ResponseWriter, r *http.Request, shareID string, threadID string, status int) {
+	comments, err := a.store.ListShareComments(r.Context(), shareID, threadID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read comments")
+		return
+	}
+	threads := commentThreads(comments)
+	if len(threads) == 0 {
+		writeError(w, http.StatusNotFound, "not_found", "thread not found")
+		return
+	}
+	writeJSON(w, status, threads[0])
+}
+
+// addComment starts a thread (threadID empty), anchored to an item of the given or current
+// version, or adds a reply to one.
+func (a *API) addComment(w http.ResponseWriter, r *http.Request, shareID string, threadID string, actorSub string) {
+	r.Body = http.MaxBytesReader(w, r.Body, a.cfg.MaxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	var req createCommentRequest
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_json", "invalid json")
+		return
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" || utf8.RuneCountInString(body) > maxCommentBody {
+		writeError(w, http.StatusBadRequest, "bad_body", fmt.Sprintf("body must be 1-%d characters", maxCommentBody))
+		return
+	}
+
+	c := store.ShareComment{ID: uuid.NewString(), ThreadID: threadID, AuthorSub: actorSub, AuthorName: strings.TrimSpace(req.Name), Body: body}
+	if actorSub != "" {
+		_, c.AuthorName = a.editorIdentity(w, r, "", "")
+	}
+
+	sh, err := a.store.GetShare(r.Context(), shareID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "share not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+		return
+	}
+	c.VersionID = strings.TrimSpace(req.VersionID)
+	if c.VersionID == "" || c.VersionID == "current" {
+		c.VersionID = sh.HeadVersionID
+	}
+	if threadID == "" && req.ItemID <= 0 {
+		writeError(w, http.StatusBadRequest, "bad_item_id", "itemId is required")
+		return
+	}
+	text := sh.Schema
+	if c.VersionID != sh.HeadVersionID {
+		var ok bool
+		if text, ok = a.readVersionSchema(w, r, shareID, c.VersionID); !ok {
+			return
+		}
+	}
+	if threadID == "" {
+		doc, err := schema.DecodeAny(text)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_schema", err.Error())
+			return
+		}
+		if _, ok := doc.ItemByID(req.ItemID); !ok {
+			writeError(w, http.StatusNotFound, "not_found", "item not found in this version")
+			return
+		}
+		c.ItemID = req.ItemID
+	}
+
+	if _, err := a.store.AddShareComment(r.Context(), shareID, c, time.Now().UTC()); err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "thread not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "db_insert_failed", "could not add comment")
+		return
+	}
+	if threadID == "" {
+		threadID = c.ID
+	}
+	a.writeCommentThread(w, r, shareID, threadID, http.StatusCreated)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"eendraadschema-share-server/internal/store"
+)
+
+// versionETag is the ETag of a share whose current version is versionID.
+func versionETag(versionID string) string { return `"` + versionID + `"` }
+
+// ifMatchVersion reads the If-Match header of a share write. It returns the head version the
+// write is conditional on, or nil for an unconditional write (no header, or "*"). head is the
+// share's head version as last read; when it is one of several listed tags it is the one used.
+// It writes 428 when the header is required but missing, and 412 when it cannot match at all.
+func (a *API) ifMatchVersion(w http.ResponseWriter, r *http.Request, shareID string, head string) (*string, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if a.cfg.RequireIfMatch {
+			writeError(w, http.StatusPreconditionRequired, "if_match_required", "send the share's ETag in If-Match")
+			return nil, false
+		}
+		return nil, true
+	}
+	if header == "*" {
+		return nil, true
+	}
+	var first *string
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// Weak tags never match (RFC 9110 uses strong comparison for If-Match).
+		if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+			continue
+		}
+		v := tag[1 : len(tag)-1]
+		if v == head {
+			return &v, true
+		}
+		if first == nil {
+			first = &v
+		}
+	}
+	if first == nil {
+		a.writeVersionMismatch(w, r, shareID)
+		return nil, false
+	}
+	return first, true
+}
+
+// writeVersionMismatch answers a write whose If-Match no longer matches with 412 and the share's
+// current version.
+func (a *API) writeVersionMismatch(w http.ResponseWriter, r *http.Request, shareID string) {
+	head, err := a.store.GetShareHeadVersion(r.Context(), shareID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "share not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+		return
+	}
+	w.Header().Set("ETag", versionETag(head))
+	writeJSON(w, http.StatusPreconditionFailed, map[string]any{
+		"error":            "version_mismatch",
+		"message":          "the share was changed since it was read",
+		"currentVersionId": head,
+	})
+}
+
+// setVersionETag sets the ETag of a share's current version after a write and returns the
+// version (best-effort; empty when it cannot be read).
+func (a *API) setVersionETag(w http.ResponseWriter, r *http.Request, shareID string) string {
+	head, err := a.store.GetShareHeadVersion(r.Context(), shareID)
+	if err != nil {
+		return ""
+	}
+	w.Header().Set("ETag", versionETag(head))
+	return head
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"eendraadschema-share-server/internal/schema"
+)
+
+// handleShareVersionDiff serves GET /api/shares/{id}/versions/{from}/diff/{to}.
+func (a *API) handleShareVersionDiff(w http.ResponseWriter, r *http.Request, shareID string, fromID string, toID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	fromText, ok := a.readVersionSchema(w, r, shareID, fromID)
+	if !ok {
+		return
+	}
+	toText, ok := a.readVersionSchema(w, r, shareID, toID)
+	if !ok {
+		return
+	}
+	from, ok := decodeStoredSchema(w, fromText)
+	if !ok {
+		return
+	}
+	to, ok := decodeStoredSchema(w, toText)
+	if !ok {
+		return
+	}
+	d := schema.Compare(from, to)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"shareId":    shareID,
+		"from":       fromID,
+		"to":         toID,
+		"counts":     d.Counts(),
+		"items":      d.Items,
+		"properties": d.Properties,
+		"sitplan":    d.SitPlan,
+	})
+}
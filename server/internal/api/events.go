@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"eendraadschema-share-server/internal/events"
+)
+
+// eventKeepAlive is how often an idle event stream gets a comment line, so that proxies keep the
+// connection open.
+const eventKeepAlive = 25 * time.Second
+
+func (a *API) publishShareEvent(ev events.Event) {
+	a.events.Publish(ev)
+}
+
+func eventJSON(ev events.Event) map[string]any {
+	out := map[string]any{
+		"type":     ev.Type,
+		"shareId":  ev.ShareID,
+		"actorSub": ev.ActorSub,
+		"at":       ev.At.UTC().Format(time.RFC3339),
+	}
+	if ev.VersionID != "" {
+		out["versionId"] = ev.VersionID
+	}
+	// Names too long to relay between instances are left out; clients then re-read the share.
+	if ev.Type == events.Rename && ev.Name != "" {
+		out["name"] = ev.Name
+	}
+	return out
+}
+
+// handleShareEvents serves GET /api/shares/{id}/events, a Server-Sent Events stream of the
+// share's update, rename, restore and delete events. The stream ends after a delete, and when the
+// client falls too far behind (it should then reload the share and reconnect). Browsers authorize
+// with a share ticket, since EventSource cannot send a bearer token.
+func (a *API) handleShareEvents(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	if _, ok := a.canReadShare(w, r, id); !ok {
+		return
+	}
+	ch, cancel := a.events.Subscribe(id)
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	// The server's write timeout is meant for ordinary responses.
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprint(w, ": connected\n\n"); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	t := time.NewTicker(eventKeepAlive)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-t.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(eventJSON(ev))
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+			if ev.Type == events.Delete {
+				_ = rc.Flush()
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+
+	"eendraadschema-share-server/internal/export"
+)
+
+// handleShareExport serves GET /api/shares/{id}/export?format=csv|xlsx: the circuit table with one
+// row per kring.
+func (a *API) handleShareExport(w http.ResponseWriter, r *http.Request, shareID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	if _, ok := a.canAccessShare(w, r, shareID); !ok {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		writeError(w, http.StatusBadRequest, "bad_format", "format must be csv or xlsx")
+		return
+	}
+	_, doc, ok := a.readShareDocument(w, r, shareID)
+	if !ok {
+		return
+	}
+	sheet := export.CircuitSheet(doc)
+
+	// Render into memory first so a failure can still be reported as JSON.
+	var buf bytes.Buffer
+	contentType := "text/csv; charset=utf-8"
+	var err error
+	if format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = sheet.WriteXLSX(&buf)
+	} else {
+		err = sheet.WriteCSV(&buf)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "export_failed", "failed to export circuits")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="kringen-`+shareID+`.`+format+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
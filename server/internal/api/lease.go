@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"eendraadschema-share-server/internal/store"
+)
+
+type leaseRequest struct {
+	// ClientID identifies the editor when OIDC is not used (e.g. a random id per browser tab);
+	// Name is shown to others in that case.
+	ClientID string `json:"clientId"`
+	Name     string `json:"name"`
+	// Force takes the lease over from another holder.
+	Force    bool   `json:"force"`
+	Password string `json:"password"`
+}
+
+// editorIdentity returns the id and display name of the caller for edit leases and presence: the
+// user with OIDC, otherwise the client id from the request body or the clientId query parameter.
+// The id is empty when the caller cannot be identified.
+func (a *API) editorIdentity(w http.ResponseWriter, r *http.Request, clientID string, name string) (string, string) {
+	if a.oidcEnabled() {
+		u, ok := a.requireUser(w, r)
+		if !ok {
+			return "", ""
+		}
+		if strings.TrimSpace(u.Name) != "" {
+			return u.Sub, u.Name
+		}
+		return u.Sub, u.Email
+	}
+	if clientID = strings.TrimSpace(clientID); clientID == "" {
+		clientID = strings.TrimSpace(r.URL.Query().Get("clientId"))
+	}
+	if clientID == "" {
+		return "", ""
+	}
+	return "client:" + clientID, strings.TrimSpace(name)
+}
+
+func (a *API) leaseJSON(l store.ShareLease, holderID string) map[string]any {
+	out := map[string]any{
+		"leased":     true,
+		"held":       holderID != "" && l.HolderID == holderID,
+		"holderName": l.HolderName,
+		"acquiredAt": l.AcquiredAt.UTC().Format(time.RFC3339),
+		"expiresAt":  l.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+	// Client ids act as credentials for the lease, subjects don't.
+	if a.oidcEnabled() {
+		out["holderSub"] = l.HolderID
+	}
+	return out
+}
+
+// writeLeaseHeld answers a request refused because someone else holds the share's edit lease.
+func (a *API) writeLeaseHeld(w http.ResponseWriter, l store.ShareLease) {
+	writeJSON(w, http.StatusLocked, map[string]any{
+		"error":   "share_leased",
+		"message": "another editor holds the edit lease of this share",
+		"lease":   a.leaseJSON(l, ""),
+	})
+}
+
+// checkShareLease allows a write when nobody else holds an unexpired edit lease on the share.
+func (a *API) checkShareLease(w http.ResponseWriter, r *http.Request, shareID string, clientID string, now time.Time) bool {
+	l, err := a.store.GetShareLease(r.Context(), shareID, now)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return true
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read lease")
+		return false
+	}
+	holderID, _ := a.editorIdentity(w, r, clientID, "")
+	if holderID != "" && l.HolderID == holderID {
+		return true
+	}
+	a.writeLeaseHeld(w, l)
+	return false
+}
+
+// handleShareLease serves /api/shares/{id}/lease: GET shows who holds the edit lease, POST
+// acquires or renews it for the caller (with "force" to take it over) and DELETE releases it.
+func (a *API) handleShareLease(w http.ResponseWriter, r *http.Request, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := a.canAccessShare(w, r, id); !ok {
+			return
+		}
+		l, err := a.store.GetShareLease(r.Context(), id, time.Now().UTC())
+		if err != nil {
+			if err == store.ErrNotFound {
+				writeJSON(w, http.StatusOK, map[string]any{"leased": false})
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read lease")
+			return
+		}
+		holderID, _ := a.editorIdentity(w, r, "", "")
+		writeJSON(w, http.StatusOK, a.leaseJSON(l, holderID))
+	case http.MethodPost, http.MethodDelete:
+		r.Body = http.MaxBytesReader(w, r.Body, a.cfg.MaxBodyBytes)
+		var req leaseRequest
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, "bad_json", "invalid json")
+			return
+		}
+		sh, err := a.store.GetShare(r.Context(), id)
+		if err != nil {
+			if err == store.ErrNotFound {
+				writeError(w, http.StatusNotFound, "not_found", "share not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+			return
+		}
+		now := time.Now().UTC()
+		if !a.authorizeShareUpdate(w, r, sh, req.Password, now) {
+			return
+		}
+		holderID, holderName := a.editorIdentity(w, r, req.ClientID, req.Name)
+		if holderID == "" {
+			writeError(w, http.StatusBadRequest, "client_id_required", "clientId is required")
+			return
+		}
+		if r.Method == http.MethodDelete {
+			if err := a.store.ReleaseShareLease(r.Context(), id, holderID); err != nil {
+				if err == store.ErrNotFound {
+					writeError(w, http.StatusNotFound, "not_found", "you do not hold the lease")
+					return
+				}
+				writeError(w, http.StatusInternalServerError, "db_delete_failed", "could not release lease")
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"leased": false, "released": true})
+			return
+		}
+		a.store.CleanupExpiredLeases(r.Context(), now)
+		l, err := a.store.AcquireShareLease(r.Context(), id, holderID, holderName, req.Force, now.Add(a.cfg.LeaseTTL), now)
+		if err != nil {
+			if err == store.ErrLeaseHeld {
+				a.writeLeaseHeld(w, l)
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "db_update_failed", "could not acquire lease")
+			return
+		}
+		writeJSON(w, http.StatusOK, a.leaseJSON(l, holderID))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"eendraadschema-share-server/internal/lint"
+	"eendraadschema-share-server/internal/schema"
+)
+
+func writeLintResult(w http.ResponseWriter, findings []lint.Finding, extra map[string]any) {
+	out := map[string]any{"counts": lint.Counts(findings), "findings": findings}
+	for k, v := range extra {
+		out[k] = v
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleLint serves POST /api/lint. The body is a schema string, either raw or as
+// {"schema": "...", "password": "..."}. Nothing is stored, but decoding a schema can inflate it to
+// far more than the body size, so it takes the same authentication as creating a share.
+func (a *API) handleLint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, a.cfg.MaxBodyBytes)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "body_too_large", "request body too large")
+		return
+	}
+	text := strings.TrimSpace(string(body))
+	password := ""
+	if strings.HasPrefix(text, "{") {
+		var req struct {
+			Schema   string `json:"schema"`
+			Password string `json:"password"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_json", "invalid json")
+			return
+		}
+		text = req.Schema
+		password = req.Password
+	}
+	if a.oidcEnabled() {
+		if _, ok := a.requireUser(w, r); !ok {
+			return
+		}
+	} else if !a.requireAuth(w, r, time.Now().UTC(), password, "") {
+		return
+	}
+	if text == "" {
+		writeError(w, http.StatusBadRequest, "missing_schema", "schema is required")
+		return
+	}
+	doc, err := schema.DecodeAny(text)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_schema", err.Error())
+		return
+	}
+	writeLintResult(w, lint.Run(doc), nil)
+}
+
+// handleShareLint serves GET /api/shares/{id}/lint for the share's current schema.
+func (a *API) handleShareLint(w http.ResponseWriter, r *http.Request, shareID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	if _, ok := a.canAccessShare(w, r, shareID); !ok {
+		return
+	}
+	_, doc, ok := a.readShareDocument(w, r, shareID)
+	if !ok {
+		return
+	}
+	writeLintResult(w, lint.Run(doc), map[string]any{"shareId": shareID})
+}
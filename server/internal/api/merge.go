@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"eendraadschema-share-server/internal/events"
+	"eendraadschema-share-server/internal/schema"
+	"eendraadschema-share-server/internal/store"
+)
+
+// mergeShareUpdate handles a schema update whose If-Match version (baseID) is no longer the
+// share's head. It merges the incoming schema with the head, both derived from the base version,
+// and saves the result as a new version, or answers 409 with the items edited on both sides.
+// When the base version is gone (pruned) or the head moves again meanwhile it answers 412.
+func (a *API) mergeShareUpdate(w http.ResponseWriter, r *http.Request, id string, baseID string, incoming string, req updateShareRequest, now time.Time) {
+	base, err := a.store.GetShareVersion(r.Context(), id, baseID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			a.writeVersionMismatch(w, r, id)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share version")
+		return
+	}
+	sh, err := a.store.GetShare(r.Context(), id)
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "share not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+		return
+	}
+	res, err := schema.Merge(base, sh.Schema, incoming)
+	if err != nil {
+		// Typically an old version the server can no longer decode; let the client merge.
+		a.writeVersionMismatch(w, r, id)
+		return
+	}
+	if len(res.Conflicts) > 0 {
+		w.Header().Set("ETag", versionETag(sh.HeadVersionID))
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error":            "merge_conflict",
+			"message":          "the share was changed since it was read and the changes overlap",
+			"baseVersionId":    baseID,
+			"currentVersionId": sh.HeadVersionID,
+			"conflicts":        res.Conflicts,
+		})
+		return
+	}
+	if !a.checkStrictSchema(w, res.Schema) {
+		return
+	}
+	actorSub := ""
+	if a.oidcEnabled() {
+		if u, ok := a.requireUser(w, r); ok {
+			actorSub = u.Sub
+		}
+	}
+	head := sh.HeadVersionID
+	version := newShareVersion(res.Schema, actorSub, store.ShareVersionInfo{Kind: store.VersionKindMerge, Message: req.Message, ParentVersionID: head})
+	if err := a.store.UpdateShareFields(r.Context(), id, &res.Schema, req.Name, &head, version, now); err != nil {
+		if err == store.ErrVersionMismatch {
+			a.writeVersionMismatch(w, r, id)
+			return
+		}
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "share not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "db_update_failed", "could not update share")
+		return
+	}
+	head = a.setVersionETag(w, r, id)
+	out := map[string]any{"id": id, "updated": true, "merged": true, "versionId": head}
+	if len(res.Renumbered) > 0 {
+		renumbered := make(map[string]int, len(res.Renumbered))
+		for from, to := range res.Renumbered {
+			renumbered[strconv.Itoa(from)] = to
+		}
+		out["renumbered"] = renumbered
+	}
+	a.publishShareEvent(events.Event{Type: events.Update, ShareID: id, VersionID: head, ActorSub: actorSub, At: now})
+	if req.Name != nil {
+		a.publishShareEvent(events.Event{Type: events.Rename, ShareID: id, VersionID: head, ActorSub: actorSub, Name: strings.TrimSpace(*req.Name), At: now})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"eendraadschema-share-server/internal/jsonpatch"
+	"eendraadschema-share-server/internal/schema"
+	"eendraadschema-share-server/internal/store"
+)
+
+type patchShareRequest struct {
+	// BaseVersionID is the version the patch was made against; If-Match is used when it is empty.
+	BaseVersionID string          `json:"baseVersionId"`
+	Patch         json.RawMessage `json:"patch"`
+	Password      string          `json:"password"`
+	Message       string          `json:"message"`
+	ClientID      string          `json:"clientId"`
+}
+
+// handlePatchShare serves PATCH /api/shares/{id}: a JSON Patch (RFC 6902) against the decoded
+// schema of a base version. The patched schema is sealed in the base's format and saved like a
+// PUT conditional on the base, so a patch against an older version is merged with the head.
+func (a *API) handlePatchShare(w http.ResponseWriter, r *http.Request, id string) {
+	r.Body = http.MaxBytesReader(w, r.Body, a.cfg.MaxBodyBytes)
+	var req patchShareRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json-patch+json") {
+		// A bare patch document; the base version comes from If-Match.
+		if err := json.NewDecoder(r.Body).Decode(&req.Patch); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_json", "invalid json")
+			return
+		}
+	} else {
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_json", "invalid json")
+			return
+		}
+	}
+	if len(bytes.TrimSpace(req.Patch)) == 0 {
+		writeError(w, http.StatusBadRequest, "missing_patch", "patch is required")
+		return
+	}
+	if !checkVersionMessage(w, req.Message) {
+		return
+	}
+
+	sh, err := a.store.GetShare(r.Context(), id)
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "share not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+		return
+	}
+	now := time.Now().UTC()
+	if !a.authorizeShareUpdate(w, r, sh, req.Password, now) {
+		return
+	}
+	if !a.checkShareLease(w, r, id, req.ClientID, now) {
+		return
+	}
+
+	baseID := strings.TrimSpace(req.BaseVersionID)
+	if baseID == "" {
+		ifMatch, ok := a.ifMatchVersion(w, r, id, sh.HeadVersionID)
+		if !ok {
+			return
+		}
+		if ifMatch == nil {
+			writeError(w, http.StatusPreconditionRequired, "base_version_required", "send baseVersionId or the share's ETag in If-Match")
+			return
+		}
+		baseID = *ifMatch
+	}
+	base := sh.Schema
+	if baseID != sh.HeadVersionID {
+		if base, err = a.store.GetShareVersion(r.Context(), id, baseID); err != nil {
+			if err == store.ErrNotFound {
+				a.writeVersionMismatch(w, r, id)
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share version")
+			return
+		}
+	}
+
+	env, err := schema.Open(base)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_schema", err.Error())
+		return
+	}
+	patched, err := jsonpatch.Apply(env.JSON, req.Patch)
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			writeError(w, http.StatusConflict, "patch_test_failed", err.Error())
+			return
+		}
+		writeError(w, http.StatusUnprocessableEntity, "bad_patch", err.Error())
+		return
+	}
+	text, err := schema.Seal(env.Format, env.Version, patched)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_schema", err.Error())
+		return
+	}
+	text, ok := a.prepareSchema(w, text)
+	if !ok {
+		return
+	}
+	a.commitShareUpdate(w, r, id, &text, &baseID, updateShareRequest{Message: req.Message}, now)
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"eendraadschema-share-server/internal/store"
+)
+
+type presenceRequest struct {
+	// ClientID and Name identify the client when OIDC is not used, as for edit leases.
+	ClientID string `json:"clientId"`
+	Name     string `json:"name"`
+	// State is "viewing" (the default) or "editing".
+	State string `json:"state"`
+}
+
+// handleSharePresence serves /api/shares/{id}/presence: POST is a heartbeat of the caller and GET
+// lists who has the share open. Both return the current list; clients drop out of it when they
+// have not sent a heartbeat for the presence timeout.
+func (a *API) handleSharePresence(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	actorSub, ok := a.canAccessShare(w, r, id)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	since := now.Add(-a.cfg.PresenceTimeout)
+
+	var req presenceRequest
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, a.cfg.MaxBodyBytes)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, "bad_json", "invalid json")
+			return
+		}
+		req.State = strings.TrimSpace(req.State)
+		if req.State == "" {
+			req.State = store.PresenceViewing
+		}
+		if req.State != store.PresenceViewing && req.State != store.PresenceEditing {
+			writeError(w, http.StatusBadRequest, "bad_state", `state must be "viewing" or "editing"`)
+			return
+		}
+	}
+	// The caller is the user canAccessShare resolved with OIDC, whose name is looked up when
+	// listing, otherwise the client as for edit leases.
+	self, name := actorSub, ""
+	if !a.oidcEnabled() {
+		self, name = a.editorIdentity(w, r, req.ClientID, req.Name)
+	}
+
+	if r.Method == http.MethodPost {
+		if self == "" {
+			writeError(w, http.StatusBadRequest, "client_id_required", "clientId is required")
+			return
+		}
+		p := store.SharePresence{ClientID: self, Name: name, State: req.State}
+		if a.oidcEnabled() {
+			p.UserSub = self
+		}
+		if err := a.store.TouchSharePresence(r.Context(), id, p, now); err != nil {
+			writeError(w, http.StatusInternalServerError, "db_update_failed", "could not record presence")
+			return
+		}
+		if a.presenceSweep.due(now) {
+			a.store.CleanupExpiredPresence(r.Context(), since)
+		}
+	}
+
+	entries, err := a.store.ListSharePresence(r.Context(), id, since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read presence")
+		return
+	}
+	subs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.UserSub != "" {
+			subs = append(subs, e.UserSub)
+		}
+	}
+	users, _ := a.store.GetUsersBySubs(r.Context(), subs)
+
+	out := make([]map[string]any, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name
+		if u, ok := users[e.UserSub]; ok {
+			name = u.Name
+			if strings.TrimSpace(name) == "" {
+				name = u.Email
+			}
+		}
+		item := map[string]any{
+			"name":   name,
+			"state":  e.State,
+			"seenAt": e.SeenAt.UTC().Format(time.RFC3339),
+			"self":   self != "" && e.ClientID == self,
+		}
+		if e.UserSub != "" {
+			item["sub"] = e.UserSub
+		}
+		out = append(out, item)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
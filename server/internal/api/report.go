@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"eendraadschema-share-server/internal/report"
+)
+
+// handleShareReport serves GET /api/shares/{id}/report.pdf.
+func (a *API) handleShareReport(w http.ResponseWriter, r *http.Request, shareID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	if _, ok := a.canAccessShare(w, r, shareID); !ok {
+		return
+	}
+	sh, doc, ok := a.readShareDocument(w, r, shareID)
+	if !ok {
+		return
+	}
+	pdf := report.Render(sh.Name, sh.UpdatedAt, doc)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="rapport-`+shareID+`.pdf"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(pdf)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"eendraadschema-share-server/internal/retention"
+)
+
+type teamRetentionRequest struct {
+	Policy string `json:"policy"`
+}
+
+// handleTeamRetention serves GET/PUT /api/teams/{id}/retention: the version retention policy of
+// the team's shares. Members can read it, owners can change it; an empty policy restores the
+// server default.
+func (a *API) handleTeamRetention(w http.ResponseWriter, r *http.Request, teamID string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	u, ok := a.requireUser(w, r)
+	if !ok {
+		return
+	}
+	role, isMember, err := a.store.IsTeamMember(r.Context(), teamID, u.Sub)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read team membership")
+		return
+	}
+	if !isMember {
+		writeError(w, http.StatusForbidden, "forbidden", "not a team member")
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		if role != "owner" {
+			writeError(w, http.StatusForbidden, "forbidden", "only team owners can change the retention policy")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, a.cfg.MaxBodyBytes)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		var req teamRetentionRequest
+		if err := dec.Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_json", "invalid json")
+			return
+		}
+		policy := ""
+		if strings.TrimSpace(req.Policy) != "" {
+			p, err := retention.Parse(req.Policy)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "bad_policy", err.Error())
+				return
+			}
+			policy = p.String()
+			if p.IsZero() {
+				policy = "all"
+			}
+		}
+		if err := a.store.SetTeamRetentionPolicy(r.Context(), teamID, policy); err != nil {
+			writeError(w, http.StatusInternalServerError, "db_update_failed", "could not update retention policy")
+			return
+		}
+	}
+
+	policy, err := a.store.GetTeamRetentionPolicy(r.Context(), teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read retention policy")
+		return
+	}
+	effective := policy
+	if effective == "" {
+		effective = a.cfg.VersionRetention
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"policy": policy, "effective": effective, "versionsMax": a.cfg.ShareVersionsMax})
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"eendraadschema-share-server/internal/store"
+)
+
+type reviewResponse struct {
+	Status string `json:"status"`
+	BySub  string `json:"bySub,omitempty"`
+	At     string `json:"at,omitempty"`
+	// VersionID is the version that was submitted, approved or rejected.
+	VersionID string `json:"versionId,omitempty"`
+	Note      string `json:"note,omitempty"`
+}
+
+func reviewJSON(r store.ShareReview) reviewResponse {
+	out := reviewResponse{Status: r.Status, BySub: r.BySub, VersionID: r.VersionID, Note: r.Note}
+	if !r.At.IsZero() {
+		out.At = r.At.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+type reviewRequest struct {
+	// Action is "submit", "withdraw", "approve" or "reject".
+	Action string `json:"action"`
+	// VersionID is the version being reviewed. It must be the current one, and is required to
+	// approve or reject; submit and withdraw default to the current version.
+	VersionID string `json:"versionId"`
+	Note      string `json:"note"`
+	Password  string `json:"password"`
+}
+
+// reviewTransitions maps each action to the states it applies to and the state it leads to.
+var reviewTransitions = map[string]struct {
+	from []string
+	to   string
+}{
+	"submit":   {from: []string{store.ReviewDraft, store.ReviewRejected, store.ReviewSubmitted}, to: store.ReviewSubmitted},
+	"withdraw": {from: []string{store.ReviewSubmitted}, to: store.ReviewDraft},
+	"approve":  {from: []string{store.ReviewSubmitted}, to: store.ReviewApproved},
+	"reject":   {from: []string{store.ReviewSubmitted}, to: store.ReviewRejected},
+}
+
+// handleShareReview serves /api/shares/{id}/review: GET returns the review state and POST moves
+// it (draft -> submitted -> approved or rejected). With OIDC, only the team owner approves or
+// rejects team shares, and only the owner personal ones.
+func (a *API) handleShareReview(w http.ResponseWriter, r *http.Request, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := a.canAccessShare(w, r, id); !ok {
+			return
+		}
+		sh, err := a.store.GetShare(r.Context(), id)
+		if err != nil {
+			if err == store.ErrNotFound {
+				writeError(w, http.StatusNotFound, "not_found", "share not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+			return
+		}
+		writeJSON(w, http.StatusOK, reviewJSON(sh.Review))
+		return
+	case http.MethodPost:
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, a.cfg.MaxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	var req reviewRequest
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_json", "invalid json")
+		return
+	}
+	action := strings.TrimSpace(req.Action)
+	t, ok := reviewTransitions[action]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "bad_action", `action must be "submit", "withdraw", "approve" or "reject"`)
+		return
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(req.Note)) > maxVersionMessage {
+		writeError(w, http.StatusBadRequest, "note_too_long", fmt.Sprintf("note is limited to %d characters", maxVersionMessage))
+		return
+	}
+	versionID := strings.TrimSpace(req.VersionID)
+	if versionID == "" && (action == "approve" || action == "reject") {
+		writeError(w, http.StatusBadRequest, "missing_version", "versionId is required to approve or reject")
+		return
+	}
+
+	sh, err := a.store.GetShare(r.Context(), id)
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "share not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+		return
+	}
+	now := time.Now().UTC()
+	if !a.authorizeShareUpdate(w, r, sh, req.Password, now) {
+		return
+	}
+	actorSub := ""
+	if a.oidcEnabled() {
+		u, ok := a.requireUser(w, r)
+		if !ok {
+			return
+		}
+		actorSub = u.Sub
+		if action == "approve" || action == "reject" {
+			if !a.canApproveShare(w, r, sh, actorSub) {
+				return
+			}
+		}
+	}
+	if versionID == "" {
+		versionID = sh.HeadVersionID
+	}
+
+	review, err := a.store.SetShareReview(r.Context(), id, t.from, store.ShareReview{Status: t.to, BySub: actorSub, VersionID: versionID, Note: req.Note}, now)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			writeError(w, http.StatusNotFound, "not_found", "share not found")
+		case store.ErrReviewTransition:
+			writeError(w, http.StatusConflict, "bad_review_state", fmt.Sprintf("cannot %s a share that is %s", action, sh.Review.Status))
+		case store.ErrVersionMismatch:
+			a.writeVersionMismatch(w, r, id)
+		default:
+			writeError(w, http.StatusInternalServerError, "db_update_failed", "could not update review state")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, reviewJSON(review))
+}
+
+// canApproveShare checks that the user may approve or reject the share: the team owner for team
+// shares, the share owner otherwise.
+func (a *API) canApproveShare(w http.ResponseWriter, r *http.Request, sh store.Share, sub string) bool {
+	if sh.TeamID.Valid {
+		role, isMember, err := a.store.IsTeamMember(r.Context(), sh.TeamID.String, sub)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read team membership")
+			return false
+		}
+		if isMember && role == "owner" {
+			return true
+		}
+	} else if strings.TrimSpace(sh.OwnerSub) != "" && sh.OwnerSub == sub {
+		return true
+	}
+	writeError(w, http.StatusForbidden, "forbidden", "only the team owner can approve or reject")
+	return false
+}
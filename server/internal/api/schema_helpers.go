@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"eendraadschema-share-server/internal/lint"
+	"eendraadschema-share-server/internal/schema"
+	"eendraadschema-share-server/internal/store"
+)
+
+// prepareSchema decodes an incoming schema and writes a 400 if it is corrupt or structurally broken.
+// Schemas written by older frontend versions only have their envelope checked; they are stored as-is,
+// or upgraded to the current format when UpgradeLegacySchemas is enabled. With StrictSchemas, lint
+// errors are rejected too.
+func (a *API) prepareSchema(w http.ResponseWriter, text string) (string, bool) {
+	_, err := schema.Decode(text)
+	if err == nil {
+		if !a.checkStrictSchema(w, text) {
+			return "", false
+		}
+		return text, true
+	}
+	if !errors.Is(err, schema.ErrUnsupportedVersion) {
+		writeError(w, http.StatusBadRequest, "invalid_schema", err.Error())
+		return "", false
+	}
+	if a.cfg.UpgradeLegacySchemas {
+		upgraded, _, err := schema.Upgrade(text)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_schema", err.Error())
+			return "", false
+		}
+		text = upgraded
+	}
+	if !a.checkStrictSchema(w, text) {
+		return "", false
+	}
+	return text, true
+}
+
+// checkStrictSchema enforces StrictSchemas: the schema must decode (legacy versions are upgraded
+// in memory) and have no error-level lint findings. The findings are returned in the 422 body.
+func (a *API) checkStrictSchema(w http.ResponseWriter, text string) bool {
+	if !a.cfg.StrictSchemas {
+		return true
+	}
+	doc, err := schema.DecodeAny(text)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_schema", err.Error())
+		return false
+	}
+	findings := lint.Run(doc)
+	if lint.HasErrors(findings) {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":    "lint_failed",
+			"message":  "schema has lint errors",
+			"counts":   lint.Counts(findings),
+			"findings": findings,
+		})
+		return false
+	}
+	return true
+}
+
+// decodeStoredSchema decodes a schema read from the database, upgrading legacy versions in memory.
+// It writes a 422 when the stored schema cannot be understood.
+func decodeStoredSchema(w http.ResponseWriter, text string) (*schema.Document, bool) {
+	doc, err := schema.DecodeAny(text)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_schema", err.Error())
+		return nil, false
+	}
+	return doc, true
+}
+
+// readShareDocument reads a share and decodes its current schema.
+func (a *API) readShareDocument(w http.ResponseWriter, r *http.Request, shareID string) (store.Share, *schema.Document, bool) {
+	sh, err := a.store.GetShare(r.Context(), shareID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "share not found")
+			return store.Share{}, nil, false
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+		return store.Share{}, nil, false
+	}
+	doc, ok := decodeStoredSchema(w, sh.Schema)
+	if !ok {
+		return store.Share{}, nil, false
+	}
+	return sh, doc, true
+}
+
+// readVersionSchema returns the stored schema of a share version.
+// The pseudo version id "current" refers to the share's current schema.
+func (a *API) readVersionSchema(w http.ResponseWriter, r *http.Request, shareID string, verID string) (string, bool) {
+	if verID == "current" {
+		sh, err := a.store.GetShare(r.Context(), shareID)
+		if err != nil {
+			if err == store.ErrNotFound {
+				writeError(w, http.StatusNotFound, "not_found", "share not found")
+				return "", false
+			}
+			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+			return "", false
+		}
+		return sh.Schema, true
+	}
+	text, err := a.store.GetShareVersion(r.Context(), shareID, verID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "version not found")
+			return "", false
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share version")
+		return "", false
+	}
+	return text, true
+}
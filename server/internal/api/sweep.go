@@ -0,0 +1,24 @@
+package api
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// sweepInterval is the least time between two deletions of the same kind of expired rows. The
+// rows are ignored once expired, so they only need to be deleted now and then.
+const sweepInterval = time.Minute
+
+// sweeper throttles a table-wide cleanup that would otherwise run on every request.
+type sweeper struct {
+	last atomic.Int64
+}
+
+// due reports whether the cleanup should run at now; at most one caller per interval gets true.
+func (s *sweeper) due(now time.Time) bool {
+	last := s.last.Load()
+	if last != 0 && now.Sub(time.Unix(last, 0)) < sweepInterval {
+		return false
+	}
+	return s.last.CompareAndSwap(last, now.Unix())
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"eendraadschema-share-server/internal/store"
+)
+
+type createTagRequest struct {
+	Name string `json:"name"`
+}
+
+func tagJSON(t store.ShareVersionTag) map[string]any {
+	return map[string]any{
+		"name":         t.Name,
+		"versionId":    t.VersionID,
+		"createdBySub": t.CreatedBySub,
+		"createdAt":    t.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// handleShareVersionTags serves /api/shares/{id}/versions/{ver}/tags: GET lists the version's
+// tags, POST adds one, and DELETE .../tags/{name} removes one. Access is checked by the caller.
+func (a *API) handleShareVersionTags(w http.ResponseWriter, r *http.Request, shareID string, verID string, actorSub string, rest []string) {
+	if len(rest) == 1 {
+		if r.Method != http.MethodDelete {
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+			return
+		}
+		if err := a.store.DeleteShareVersionTag(r.Context(), shareID, verID, strings.TrimSpace(rest[0])); err != nil {
+			if err == store.ErrNotFound {
+				writeError(w, http.StatusNotFound, "not_found", "tag not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "db_delete_failed", "could not delete tag")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"name": strings.TrimSpace(rest[0]), "deleted": true})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tags, err := a.store.ListShareVersionTags(r.Context(), shareID, verID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not list tags")
+			return
+		}
+		out := make([]map[string]any, 0, len(tags))
+		for _, t := range tags {
+			out = append(out, tagJSON(t))
+		}
+		writeJSON(w, http.StatusOK, out)
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, a.cfg.MaxBodyBytes)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		var req createTagRequest
+		if err := dec.Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_json", "invalid json")
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" || utf8.RuneCountInString(name) > 64 || strings.Contains(name, "/") {
+			writeError(w, http.StatusBadRequest, "bad_tag", "tag name must be 1-64 characters without '/'")
+			return
+		}
+		now := time.Now().UTC()
+		if err := a.store.AddShareVersionTag(r.Context(), shareID, verID, name, actorSub, now); err != nil {
+			switch err {
+			case store.ErrNotFound:
+				writeError(w, http.StatusNotFound, "not_found", "version not found")
+			case store.ErrTagExists:
+				writeError(w, http.StatusConflict, "tag_exists", "the share already has a tag with this name")
+			default:
+				writeError(w, http.StatusInternalServerError, "db_insert_failed", "could not create tag")
+			}
+			return
+		}
+		writeJSON(w, http.StatusCreated, tagJSON(store.ShareVersionTag{Name: name, VersionID: verID, CreatedBySub: actorSub, CreatedAt: now}))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+	}
+}
+
+// handleShareTags serves GET /api/shares/{id}/tags (all tags of the share) and
+// GET /api/shares/{id}/tags/{name} (the tagged version, like GET .../versions/{ver}).
+func (a *API) handleShareTags(w http.ResponseWriter, r *http.Request, shareID string, rest []string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	if _, ok := a.canAccessShare(w, r, shareID); !ok {
+		return
+	}
+	if len(rest) == 0 {
+		tags, err := a.store.ListShareVersionTags(r.Context(), shareID, "")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "db_read_failed", "could not list tags")
+			return
+		}
+		out := make([]map[string]any, 0, len(tags))
+		for _, t := range tags {
+			out = append(out, tagJSON(t))
+		}
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+	if len(rest) != 1 {
+		writeError(w, http.StatusNotFound, "not_found", "not found")
+		return
+	}
+	tag, err := a.store.GetShareVersionTag(r.Context(), shareID, strings.TrimSpace(rest[0]))
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "tag not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read tag")
+		return
+	}
+	text, ok := a.readVersionSchema(w, r, shareID, tag.VersionID)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"shareId": shareID, "versionId": tag.VersionID, "tag": tag.Name, "schema": text})
+}
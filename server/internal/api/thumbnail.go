@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"eendraadschema-share-server/internal/store"
+	"eendraadschema-share-server/internal/thumbnail"
+)
+
+// handleShareThumbnail serves GET /api/shares/{id}/thumbnail.svg and thumbnail.png. Thumbnails are
+// cached per schema, so each version is only drawn once, and carry an ETag for browser caching.
+func (a *API) handleShareThumbnail(w http.ResponseWriter, r *http.Request, shareID string, format string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	if _, ok := a.canReadShare(w, r, shareID); !ok {
+		return
+	}
+	sh, err := a.store.GetShare(r.Context(), shareID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "share not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+		return
+	}
+	key := fmt.Sprintf("%d-%s", thumbnail.Revision, sh.SchemaHash)
+	etag := `"` + key + "-" + format + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	thumb, err := a.store.GetShareThumbnail(r.Context(), shareID, key)
+	if err != nil {
+		doc, ok := decodeStoredSchema(w, sh.Schema)
+		if !ok {
+			return
+		}
+		d := thumbnail.Render(doc)
+		png, err := d.PNG()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "render_failed", "could not render thumbnail")
+			return
+		}
+		thumb = store.ShareThumbnail{SVG: d.SVG(), PNG: png}
+		// Caching is best-effort; the thumbnail is served either way.
+		_ = a.store.PutShareThumbnail(r.Context(), shareID, key, thumb, time.Now())
+	}
+
+	body, contentType := thumb.SVG, "image/svg+xml"
+	if format == "png" {
+		body, contentType = thumb.PNG, "image/png"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"eendraadschema-share-server/internal/store"
+)
+
+// shareTicketTTL is how long a share ticket can be used. A ticket may be used more than once, e.g.
+// by an EventSource that reconnects, until it expires.
+const shareTicketTTL = 5 * time.Minute
+
+// handleShareTicket serves POST /api/shares/{id}/ticket. It issues a short-lived ticket that
+// stands in for the caller's credentials, as ?ticket=, on GET .../events and .../thumbnail.*:
+// browsers open those through EventSource and <img>, which cannot send a bearer token.
+func (a *API) handleShareTicket(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	actorSub, ok := a.canAccessShare(w, r, id)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	if a.ticketSweep.due(now) {
+		a.store.CleanupExpiredTickets(r.Context(), now)
+	}
+	token := uuid.NewString()
+	exp := now.Add(shareTicketTTL)
+	if err := a.store.CreateShareTicket(r.Context(), token, id, actorSub, exp, now); err != nil {
+		writeError(w, http.StatusInternalServerError, "db_insert_failed", "could not create ticket")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ticket": token, "expiresAt": exp.Format(time.RFC3339)})
+}
+
+// listTicket issues the ticket a share list hands out in its thumbnail URLs: one for every share
+// the user can read, so a list needs only one. It returns "" for an empty list.
+func (a *API) listTicket(w http.ResponseWriter, r *http.Request, userSub string, n int) (string, bool) {
+	if n == 0 {
+		return "", true
+	}
+	now := time.Now().UTC()
+	if a.ticketSweep.due(now) {
+		a.store.CleanupExpiredTickets(r.Context(), now)
+	}
+	token := uuid.NewString()
+	if err := a.store.CreateShareTicket(r.Context(), token, "", userSub, now.Add(shareTicketTTL), now); err != nil {
+		writeError(w, http.StatusInternalServerError, "db_insert_failed", "could not create ticket")
+		return "", false
+	}
+	return token, true
+}
+
+func thumbnailURL(shareID string, ticket string) string {
+	u := "/api/shares/" + shareID + "/thumbnail.svg"
+	if ticket != "" {
+		u += "?ticket=" + url.QueryEscape(ticket)
+	}
+	return u
+}
+
+// canReadShare is canAccessShare for the endpoints that also accept a share ticket. A ticket from
+// a share list counts for the shares its user can read now, or for every share if they are an admin.
+func (a *API) canReadShare(w http.ResponseWriter, r *http.Request, shareID string) (string, bool) {
+	ticket := strings.TrimSpace(r.URL.Query().Get("ticket"))
+	if ticket == "" {
+		return a.canAccessShare(w, r, shareID)
+	}
+	t, err := a.store.GetShareTicket(r.Context(), ticket, shareID, time.Now().UTC())
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusUnauthorized, "invalid_ticket", "ticket is invalid or has expired")
+			return "", false
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read ticket")
+		return "", false
+	}
+	if t.ShareID != "" {
+		return t.UserSub, true
+	}
+	sh, err := a.store.GetShare(r.Context(), shareID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, "not_found", "share not found")
+			return "", false
+		}
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read share")
+		return "", false
+	}
+	ok, err := a.isShareMember(r.Context(), sh, t.UserSub)
+	if err == nil && !ok {
+		ok, err = a.store.IsUserAdmin(r.Context(), t.UserSub)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db_read_failed", "could not read user")
+		return "", false
+	}
+	if !ok {
+		writeError(w, http.StatusForbidden, "forbidden", "not allowed")
+		return "", false
+	}
+	return t.UserSub, true
+}
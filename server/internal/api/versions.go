@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"eendraadschema-share-server/internal/schema"
+	"eendraadschema-share-server/internal/store"
+)
+
+// newShareVersion describes a new version of a share with text as its schema, for the store to
+// record together with the share. Old versions are pruned by the retention job.
+func newShareVersion(text string, actorSub string, info store.ShareVersionInfo) store.ShareVersionInfo {
+	info.ID = uuid.NewString()
+	info.CreatedBySub = actorSub
+	info.Summarize = func(prev string) string { return summarizeVersion(prev, text) }
+	return info
+}
+
+// maxVersionMessage is the longest version message accepted, in characters.
+const maxVersionMessage = 1000
+
+// checkVersionMessage rejects version messages that are too long.
+func checkVersionMessage(w http.ResponseWriter, message string) bool {
+	if utf8.RuneCountInString(strings.TrimSpace(message)) > maxVersionMessage {
+		writeError(w, http.StatusBadRequest, "message_too_long", fmt.Sprintf("message is limited to %d characters", maxVersionMessage))
+		return false
+	}
+	return true
+}
+
+// writeKind returns the version kind of a create or update.
+func writeKind(imported bool, kind string) string {
+	if imported {
+		return store.VersionKindImport
+	}
+	return kind
+}
+
+// summarizeVersion describes the change from prevText, the schema being replaced, to text. An
+// empty prevText describes text as the first version. It returns "" when either side cannot be
+// decoded.
+func summarizeVersion(prevText string, text string) string {
+	next, err := schema.DecodeAny(text)
+	if err != nil {
+		return ""
+	}
+	if prevText == "" {
+		return schema.Summarize(nil, next)
+	}
+	prev, err := schema.DecodeAny(prevText)
+	if err != nil {
+		return ""
+	}
+	return schema.Summarize(prev, next)
+}
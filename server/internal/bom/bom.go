@@ -0,0 +1,232 @@
+// Package bom computes the bill of materials of a schema: devices in pieces and cables in metres.
+// It is a port of src/bom/materials.ts.
+package bom
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"eendraadschema-share-server/internal/schema"
+	"eendraadschema-share-server/internal/sitplan"
+)
+
+type Unit string
+
+const (
+	UnitMeters Unit = "m"
+	UnitPieces Unit = "pcs"
+)
+
+// NoKring is the kring name used for devices that are not below a named kring.
+const NoKring = "Zonder naam"
+
+type Line struct {
+	Key      string  `json:"key"`
+	Label    string  `json:"label"`
+	Unit     Unit    `json:"unit"`
+	Quantity float64 `json:"quantity"`
+}
+
+// Kring holds the materials of one kring. The Unknown* counts are cable endpoints or runs that could
+// not be measured; Warnings describes them the way the materials page does.
+type Kring struct {
+	Kring                string   `json:"kring"`
+	Label                string   `json:"label"`
+	Cables               []Line   `json:"cables"`
+	Devices              []Line   `json:"devices"`
+	UnknownScaleRuns     int      `json:"unknownScaleRuns"`
+	UnknownRiserCount    int      `json:"unknownRiserCount"`
+	UnknownEndpointCount int      `json:"unknownEndpointCount"`
+	Warnings             []string `json:"warnings"`
+}
+
+type BOM struct {
+	Cables  []Line  `json:"cables"`
+	Devices []Line  `json:"devices"`
+	Kringen []Kring `json:"kringen"`
+}
+
+// Compute returns the totals and the per-kring materials of a schema.
+// Kringen without any materials are left out.
+func Compute(doc *schema.Document) *BOM {
+	devicesByKring := map[string][]Line{}
+	var deviceTotals []Line
+	kringSet := map[string]bool{}
+	for i := range doc.Data {
+		if !doc.Active[i] {
+			continue
+		}
+		lines := deviceLines(&doc.Data[i])
+		if len(lines) == 0 {
+			continue
+		}
+		kring := doc.FindKringName(doc.Data[i].ID)
+		if kring == "" {
+			kring = NoKring
+		}
+		kringSet[kring] = true
+		for _, l := range lines {
+			devicesByKring[kring] = addLine(devicesByKring[kring], l)
+			deviceTotals = addLine(deviceTotals, l)
+		}
+	}
+	for _, k := range sitplan.Kringen(doc) {
+		kringSet[k] = true
+	}
+	labels := doc.KringLabels()
+
+	kringen := make([]string, 0, len(kringSet))
+	for k := range kringSet {
+		kringen = append(kringen, k)
+	}
+	sortStrings(kringen)
+
+	out := &BOM{Devices: sumByKey(deviceTotals), Kringen: []Kring{}}
+	var cableTotals []Line
+	for _, k := range kringen {
+		kr := Kring{Kring: k, Label: k, Devices: sumByKey(devicesByKring[k]), Cables: []Line{}, Warnings: []string{}}
+		if l, ok := labels[k]; ok {
+			kr.Label = l
+		}
+		if doc.SitPlan != nil {
+			s := sitplan.SummaryForKring(doc, k)
+			for spec, m := range s.MetersBySpec {
+				kr.Cables = append(kr.Cables, Line{Key: "cable:" + spec, Label: spec, Unit: UnitMeters, Quantity: m})
+			}
+			sortLines(kr.Cables)
+			cableTotals = append(cableTotals, kr.Cables...)
+			kr.UnknownScaleRuns, kr.UnknownRiserCount, kr.UnknownEndpointCount = s.UnknownScaleRuns, s.UnknownRiserCount, s.UnknownEndpointCount
+			if s.UnknownScaleRuns > 0 {
+				kr.Warnings = append(kr.Warnings, fmt.Sprintf("schaal ontbreekt voor %d run(s)", s.UnknownScaleRuns))
+			}
+			if s.UnknownRiserCount > 0 {
+				kr.Warnings = append(kr.Warnings, fmt.Sprintf("hoogte ontbreekt voor %d stijgleiding(en)", s.UnknownRiserCount))
+			}
+			if s.UnknownEndpointCount > 0 {
+				kr.Warnings = append(kr.Warnings, fmt.Sprintf("verticale aansluiting onbekend voor %d eindpunt(en)", s.UnknownEndpointCount))
+			}
+		}
+		if len(kr.Devices) == 0 && len(kr.Cables) == 0 {
+			continue
+		}
+		out.Kringen = append(out.Kringen, kr)
+	}
+	out.Cables = sumByKey(cableTotals)
+	return out
+}
+
+// deviceLines returns the materials a single item contributes.
+func deviceLines(it *schema.Item) []Line {
+	p := it.Props
+	pcs := func(key, label string, n float64) []Line {
+		return []Line{{Key: key, Label: label, Unit: UnitPieces, Quantity: n}}
+	}
+	// withKind appends an optional sub type to the key and label, e.g. "Lichtpunt (TL)".
+	withKind := func(key, label, kind string) (string, string) {
+		if kind == "" {
+			return key, label
+		}
+		return key + ":" + kind, label + " (" + kind + ")"
+	}
+
+	switch it.Type() {
+	case "Contactdoos":
+		return pcs("device:contactdoos", "Contactdoos", positiveInt(p, "aantal", 1))
+	case "Schakelaars":
+		key, label := withKind("device:schakelaar", "Schakelaar", strings.TrimSpace(p.String("type_schakelaar")))
+		return pcs(key, label, positiveInt(p, "aantal_schakelaars", 1))
+	case "Drukknop":
+		// Model: aantal armaturen × aantal knoppen per armatuur.
+		key, label := withKind("device:drukknop", "Drukknop", strings.TrimSpace(p.String("type_knop")))
+		return pcs(key, label, positiveInt(p, "aantal", 1)*positiveInt(p, "aantal_knoppen_per_armatuur", 1))
+	case "Lichtpunt":
+		key, label := withKind("device:lichtpunt", "Lichtpunt", strings.TrimSpace(p.String("type_lamp")))
+		return pcs(key, label, positiveInt(p, "aantal", 1))
+	case "Aftakdoos":
+		return pcs("device:aftakdoos", "Aftakdoos", 1)
+	case "Aansluitpunt":
+		return pcs("device:aansluitpunt", "Aansluitpunt", 1)
+	case "Zekering/differentieel":
+		bescherming := strings.TrimSpace(p.String("bescherming"))
+		polen := strings.TrimSpace(p.String("aantal_polen"))
+		amp := strings.TrimSpace(p.String("amperage"))
+		delta := strings.TrimSpace(p.String("differentieel_delta_amperage"))
+		curve := strings.TrimSpace(p.String("curve_automaat"))
+		diffType := strings.TrimSpace(p.String("type_differentieel"))
+		suffix := func(prefix, v string) string {
+			if v == "" {
+				return ""
+			}
+			return " " + prefix + " " + v
+		}
+
+		label := "Zekering/differentieel"
+		switch bescherming {
+		case "automatisch":
+			label = fmt.Sprintf("Automaat %sP %sA%s", polen, amp, suffix("curve", curve))
+		case "differentieel":
+			label = fmt.Sprintf("Differentieel %sP %sA Δ%smA%s", polen, amp, delta, suffix("type", diffType))
+		case "differentieelautomaat":
+			label = fmt.Sprintf("Differentieelautomaat %sP %sA Δ%smA%s%s", polen, amp, delta, suffix("curve", curve), suffix("type", diffType))
+		case "smelt":
+			label = fmt.Sprintf("Smeltzekering %sP %sA", polen, amp)
+		}
+		key := fmt.Sprintf("device:zekering:%s|%s|%s|%s|%s|%s", bescherming, polen, amp, delta, curve, diffType)
+		return pcs(key, strings.TrimSpace(label), 1)
+	}
+	return nil
+}
+
+// positiveInt parses a count like Number.parseInt, falling back when it is missing or not positive.
+func positiveInt(p schema.Props, key string, fallback float64) float64 {
+	n, ok := p.Int(key)
+	if !ok || n <= 0 {
+		return fallback
+	}
+	return float64(n)
+}
+
+func addLine(lines []Line, l Line) []Line {
+	if math.IsNaN(l.Quantity) || math.IsInf(l.Quantity, 0) || l.Quantity <= 0 {
+		return lines
+	}
+	return append(lines, l)
+}
+
+// sumByKey merges lines with the same key and sorts them by label.
+func sumByKey(lines []Line) []Line {
+	byKey := map[string]int{}
+	out := []Line{}
+	for _, l := range lines {
+		if i, ok := byKey[l.Key]; ok {
+			out[i].Quantity += l.Quantity
+			continue
+		}
+		byKey[l.Key] = len(out)
+		out = append(out, l)
+	}
+	for i := range out {
+		out[i].Quantity = math.Floor(out[i].Quantity*10+0.5) / 10
+	}
+	sortLines(out)
+	return out
+}
+
+func sortLines(lines []Line) {
+	sort.SliceStable(lines, func(i, j int) bool { return lessFold(lines[i].Label, lines[j].Label) })
+}
+
+func sortStrings(s []string) {
+	sort.Slice(s, func(i, j int) bool { return lessFold(s[i], s[j]) })
+}
+
+// lessFold approximates localeCompare: case-insensitive first, then byte order.
+func lessFold(a, b string) bool {
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	if la != lb {
+		return la < lb
+	}
+	return a < b
+}
@@ -20,8 +20,18 @@ type Config struct {
 	CookieSecure  bool
 	SessionTTL    time.Duration
 	MaxBodyBytes  int64
-	AllowedOrigin string
-	APIPassword   string
+	// Reject share writes (create, update, version restore) whose schema cannot be decoded
+	// or has error-level lint findings. Without it, only corrupt schemas are rejected.
+	StrictSchemas bool
+	// Reject share updates without an If-Match header (428). Without it, If-Match is honoured
+	// when sent.
+	RequireIfMatch bool
+	// How long an edit lease lasts without a renewal.
+	LeaseTTL time.Duration
+	// How long a client counts as present on a share after its last presence heartbeat.
+	PresenceTimeout time.Duration
+	AllowedOrigin   string
+	APIPassword     string
 
 	// Optional OIDC config. When set, share write endpoints (create/update/list/delete)
 	// can be locked down to authenticated users.
@@ -33,6 +43,15 @@ type Config struct {
 	// Share versioning. On each create/update, we store a version row.
 	// Keep only the most recent N versions per share (0 disables pruning).
 	ShareVersionsMax int
+	// Time-based retention (see package retention), e.g. "24h,1d:30d,1w:1y". The default "all"
+	// keeps every version, so pruning by age is opt-in; teams can set their own. Both limits are
+	// applied by a background job every RetentionInterval (0 disables the job).
+	VersionRetention  string
+	RetentionInterval time.Duration
+
+	// Upgrade schemas written by older frontend versions (EDS001/002/003) to EDS0040000
+	// when they are uploaded, instead of storing them as-is.
+	UpgradeLegacySchemas bool
 
 	// Comma-separated list of OIDC subject IDs that should be treated as admins.
 	// Used to bootstrap at least one admin without manual DB edits.
@@ -54,24 +73,31 @@ func Load() Config {
 	}
 
 	cfg := Config{
-		Addr:          envString("EDS_SHARE_ADDR", ":8080"),
-		DBDriver:      envString("EDS_SHARE_DB_DRIVER", "sqlite"),
-		DBPath:        envString("EDS_SHARE_DB", "./data/shares.db"),
-		PostgresDSN:   envString("EDS_SHARE_DB_DSN", ""),
-		StaticDir:     envString("EDS_SHARE_STATIC_DIR", ""),
-		CookieName:    envString("EDS_SHARE_COOKIE", "eds_session"),
-		CookieSecure:  envBool("EDS_SHARE_COOKIE_SECURE", false),
-		SessionTTL:    envDurationHours("EDS_SHARE_SESSION_TTL_HOURS", 168), // 7 days
-		MaxBodyBytes:  envInt64("EDS_SHARE_MAX_BODY_BYTES", 8<<20),          // 8 MiB
-		AllowedOrigin: envString("EDS_SHARE_ALLOWED_ORIGIN", ""),
-		APIPassword:   envString("EDS_SHARE_PASSWORD", "ChangeMe123!"),
+		Addr:            envString("EDS_SHARE_ADDR", ":8080"),
+		DBDriver:        envString("EDS_SHARE_DB_DRIVER", "sqlite"),
+		DBPath:          envString("EDS_SHARE_DB", "./data/shares.db"),
+		PostgresDSN:     envString("EDS_SHARE_DB_DSN", ""),
+		StaticDir:       envString("EDS_SHARE_STATIC_DIR", ""),
+		CookieName:      envString("EDS_SHARE_COOKIE", "eds_session"),
+		CookieSecure:    envBool("EDS_SHARE_COOKIE_SECURE", false),
+		SessionTTL:      envDurationHours("EDS_SHARE_SESSION_TTL_HOURS", 168), // 7 days
+		MaxBodyBytes:    envInt64("EDS_SHARE_MAX_BODY_BYTES", 8<<20),          // 8 MiB
+		StrictSchemas:   envBool("EDS_SHARE_STRICT_SCHEMAS", false),
+		RequireIfMatch:  envBool("EDS_SHARE_REQUIRE_IF_MATCH", false),
+		LeaseTTL:        time.Duration(envInt("EDS_SHARE_LEASE_TTL_SECONDS", 120)) * time.Second,
+		PresenceTimeout: time.Duration(envInt("EDS_SHARE_PRESENCE_TIMEOUT_SECONDS", 45)) * time.Second,
+		AllowedOrigin:   envString("EDS_SHARE_ALLOWED_ORIGIN", ""),
+		APIPassword:     envString("EDS_SHARE_PASSWORD", "ChangeMe123!"),
 
 		OIDCIssuerURL: envString("EDS_SHARE_OIDC_ISSUER_URL", ""),
 		OIDCClientID:  envString("EDS_SHARE_OIDC_CLIENT_ID", ""),
 		OIDCAudience:  envString("EDS_SHARE_OIDC_AUDIENCE", ""),
 
-		ShareVersionsMax: envInt("EDS_SHARE_SHARE_VERSIONS_MAX", 50),
-		AdminSubs:        envStringList("EDS_SHARE_ADMIN_SUBS"),
+		ShareVersionsMax:     envInt("EDS_SHARE_SHARE_VERSIONS_MAX", 1000),
+		VersionRetention:     envString("EDS_SHARE_VERSION_RETENTION", "all"),
+		RetentionInterval:    time.Duration(envInt("EDS_SHARE_RETENTION_INTERVAL_MINUTES", 60)) * time.Minute,
+		UpgradeLegacySchemas: envBool("EDS_SHARE_UPGRADE_LEGACY_SCHEMAS", false),
+		AdminSubs:            envStringList("EDS_SHARE_ADMIN_SUBS"),
 	}
 
 	// Optional: allow providing Postgres credentials separately, so GitOps setups can
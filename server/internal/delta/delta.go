@@ -0,0 +1,152 @@
+// Package delta encodes a byte string as copies from a similar base plus literal inserts. Schema
+// versions of one share differ in a few items, so an older version stored as a delta against the
+// next newer one takes a fraction of its full size.
+package delta
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// blockSize is the length of the base blocks that are indexed; shorter matches are not found.
+const blockSize = 16
+
+// maxCandidates limits how many base offsets are tried per block hash, for repetitive input.
+const maxCandidates = 8
+
+const (
+	magic    byte = 1
+	opCopy   byte = 'c'
+	opInsert byte = 'i'
+)
+
+var ErrCorrupt = errors.New("delta is corrupt")
+
+// Encode returns a compressed delta that turns base into target.
+func Encode(base, target []byte) []byte {
+	index := map[uint64][]int{}
+	for off := 0; off+blockSize <= len(base); off += blockSize {
+		h := blockHash(base[off : off+blockSize])
+		if len(index[h]) < maxCandidates {
+			index[h] = append(index[h], off)
+		}
+	}
+
+	var ops bytes.Buffer
+	ops.WriteByte(magic)
+	writeUvarint(&ops, uint64(len(target)))
+	literal := 0 // start of the pending insert
+	flush := func(end int) {
+		if end > literal {
+			ops.WriteByte(opInsert)
+			writeUvarint(&ops, uint64(end-literal))
+			ops.Write(target[literal:end])
+		}
+	}
+	for p := 0; p+blockSize <= len(target); {
+		bestOff, bestLen := 0, 0
+		for _, off := range index[blockHash(target[p:p+blockSize])] {
+			n := matchLen(base[off:], target[p:])
+			if n > bestLen {
+				bestOff, bestLen = off, n
+			}
+		}
+		if bestLen < blockSize {
+			p++
+			continue
+		}
+		// Grow the match backwards into the pending literal.
+		for p > literal && bestOff > 0 && base[bestOff-1] == target[p-1] {
+			p, bestOff, bestLen = p-1, bestOff-1, bestLen+1
+		}
+		flush(p)
+		ops.WriteByte(opCopy)
+		writeUvarint(&ops, uint64(bestOff))
+		writeUvarint(&ops, uint64(bestLen))
+		p += bestLen
+		literal = p
+	}
+	flush(len(target))
+
+	var out bytes.Buffer
+	zw, _ := flate.NewWriter(&out, flate.BestCompression)
+	_, _ = zw.Write(ops.Bytes())
+	_ = zw.Close()
+	return out.Bytes()
+}
+
+// Apply rebuilds the target from base and a delta made by Encode.
+func Apply(base, delta []byte) ([]byte, error) {
+	ops, err := io.ReadAll(flate.NewReader(bytes.NewReader(delta)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	r := bytes.NewReader(ops)
+	if b, err := r.ReadByte(); err != nil || b != magic {
+		return nil, ErrCorrupt
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	out := make([]byte, 0, min(size, 64<<20))
+	for {
+		op, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		switch op {
+		case opCopy:
+			off, err1 := binary.ReadUvarint(r)
+			n, err2 := binary.ReadUvarint(r)
+			if err1 != nil || err2 != nil || off > uint64(len(base)) || n > uint64(len(base))-off {
+				return nil, ErrCorrupt
+			}
+			out = append(out, base[off:off+n]...)
+		case opInsert:
+			n, err := binary.ReadUvarint(r)
+			if err != nil || n > uint64(r.Len()) {
+				return nil, ErrCorrupt
+			}
+			lit := make([]byte, n)
+			_, _ = io.ReadFull(r, lit)
+			out = append(out, lit...)
+		default:
+			return nil, ErrCorrupt
+		}
+		if uint64(len(out)) > size {
+			return nil, ErrCorrupt
+		}
+	}
+	if uint64(len(out)) != size {
+		return nil, ErrCorrupt
+	}
+	return out, nil
+}
+
+// blockHash is FNV-1a, inlined because it runs at every target offset.
+func blockHash(b []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	return h
+}
+
+func matchLen(a, b []byte) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func writeUvarint(b *bytes.Buffer, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
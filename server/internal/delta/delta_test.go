@@ -0,0 +1,88 @@
+package delta
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	schema := strings.Repeat(`{"id":1,"parent":0,"props":{"type":"Kring","nr":"A"}},`, 40)
+	tests := []struct {
+		name         string
+		base, target string
+	}{
+		{"both empty", "", ""},
+		{"empty base", "", schema},
+		{"empty target", schema, ""},
+		{"identical", schema, schema},
+		{"shorter than a block", "abc", "abd"},
+		{"no overlap", strings.Repeat("a", 200), strings.Repeat("b", 200)},
+		{"edit in the middle", schema, strings.Replace(schema, `"nr":"A"`, `"nr":"B"`, 20)},
+		{"insert at the start", schema, `{"id":0},` + schema},
+		{"append", schema, schema + `{"id":99}`},
+		{"truncate", schema, schema[:len(schema)/2]},
+		{"reordered halves", schema, schema[len(schema)/2:] + schema[:len(schema)/2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Encode([]byte(tt.base), []byte(tt.target))
+			got, err := Apply([]byte(tt.base), d)
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !bytes.Equal(got, []byte(tt.target)) {
+				t.Fatalf("Apply = %q, want %q", got, tt.target)
+			}
+		})
+	}
+}
+
+func TestIdenticalIsSmall(t *testing.T) {
+	base := []byte(strings.Repeat(`{"id":1,"parent":0,"props":{"type":"Kring"}},`, 100))
+	if d := Encode(base, base); len(d) > 32 {
+		t.Fatalf("delta of identical input is %d bytes", len(d))
+	}
+}
+
+func TestApplyCorrupt(t *testing.T) {
+	base := []byte(strings.Repeat("0123456789abcdef", 8))
+	target := append(append([]byte{}, base[:64]...), "changed"...)
+	good := Encode(base, target)
+
+	tests := []struct {
+		name  string
+		base  []byte
+		delta []byte
+	}{
+		{"empty", base, nil},
+		{"not deflate", base, []byte("not a delta")},
+		{"truncated", base, good[:len(good)/2]},
+		{"short base", base[:32], good},
+		{"wrong magic", base, deflate([]byte{2, 0})},
+		{"missing size", base, deflate([]byte{magic})},
+		{"unknown op", base, deflate([]byte{magic, 1, 'x'})},
+		{"copy out of range", base, deflate([]byte{magic, 4, opCopy, 200, 1, 4})},
+		{"insert past end", base, deflate([]byte{magic, 4, opInsert, 4, 'a'})},
+		{"longer than size", base, deflate([]byte{magic, 1, opInsert, 2, 'a', 'b'})},
+		{"shorter than size", base, deflate([]byte{magic, 3, opInsert, 2, 'a', 'b'})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Apply(tt.base, tt.delta); !errors.Is(err, ErrCorrupt) {
+				t.Fatalf("Apply error = %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
+
+// deflate compresses hand-written ops the way Encode does.
+func deflate(ops []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := flate.NewWriter(&buf, flate.BestCompression)
+	_, _ = zw.Write(ops)
+	_ = zw.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,89 @@
+// Package events fans out notifications about changed shares to the clients watching them.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	Update  = "update"
+	Rename  = "rename"
+	Restore = "restore"
+	Delete  = "delete"
+)
+
+// Event is a change to a share. VersionID is the share's version after the change (empty for
+// deletes) and Name is set for renames.
+type Event struct {
+	Type      string
+	ShareID   string
+	VersionID string
+	ActorSub  string
+	Name      string
+	At        time.Time
+}
+
+// Hub publishes share events and delivers them to the subscribers connected to this instance.
+type Hub interface {
+	Publish(ev Event)
+	Subscribe(shareID string) (<-chan Event, func())
+}
+
+// subscriberBuffer is how many events a subscriber may fall behind before it is dropped.
+const subscriberBuffer = 32
+
+// Broker delivers published events to the subscribers of the same share, within the process.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[string]map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: map[string]map[chan Event]struct{}{}}
+}
+
+// Subscribe returns a channel with the events of a share and a function that ends the
+// subscription. The channel is closed when the subscription ends, including when the subscriber
+// falls too far behind; it should then reload the share and subscribe again.
+func (b *Broker) Subscribe(shareID string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	if b.subs[shareID] == nil {
+		b.subs[shareID] = map[chan Event]struct{}{}
+	}
+	b.subs[shareID][ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(shareID, ch)
+	}
+}
+
+// Publish delivers ev to the subscribers of its share without blocking.
+func (b *Broker) Publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[ev.ShareID] {
+		select {
+		case ch <- ev:
+		default:
+			b.remove(ev.ShareID, ch)
+		}
+	}
+}
+
+// remove closes a subscription once; b.mu must be held.
+func (b *Broker) remove(shareID string, ch chan Event) {
+	subs := b.subs[shareID]
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(b.subs, shareID)
+	}
+}
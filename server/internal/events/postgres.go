@@ -0,0 +1,166 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// pgChannel is the LISTEN/NOTIFY channel share events are relayed on.
+const pgChannel = "eds_share_events"
+
+const (
+	pgNotifyTimeout = 5 * time.Second
+	pgRetryDelay    = 5 * time.Second
+	// pgQueueSize is how many events may wait to be sent before Publish delivers them locally only.
+	pgQueueSize = 256
+	// pgMaxName is the longest share name relayed in a rename event. NOTIFY payloads are limited
+	// to 8000 bytes; longer names are left out and the clients re-read the share.
+	pgMaxName = 1000
+)
+
+// PostgresHub relays share events between server instances through Postgres LISTEN/NOTIFY.
+// Published events reach local subscribers when they come back from the database, like those of
+// other instances; while the listener is not connected they are delivered locally only. Events are
+// sent from a goroutine, so a slow database does not hold up the requests that publish them.
+type PostgresHub struct {
+	dsn       string
+	local     *Broker
+	listening atomic.Bool
+	queue     chan Event
+
+	// notify is only used by the sending goroutine.
+	notify *pgx.Conn
+}
+
+func NewPostgresHub(dsn string) *PostgresHub {
+	return &PostgresHub{dsn: dsn, local: NewBroker(), queue: make(chan Event, pgQueueSize)}
+}
+
+func (h *PostgresHub) Subscribe(shareID string) (<-chan Event, func()) {
+	return h.local.Subscribe(shareID)
+}
+
+func (h *PostgresHub) Publish(ev Event) {
+	if h.listening.Load() {
+		select {
+		case h.queue <- ev:
+			return
+		default:
+			log.Printf("share events: notify queue is full")
+		}
+	}
+	h.local.Publish(ev)
+}
+
+// Run sends published events and listens for events until ctx is done, reconnecting after errors.
+func (h *PostgresHub) Run(ctx context.Context) {
+	go h.sendQueued(ctx)
+	for {
+		if err := h.listen(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("share events: listen: %v", err)
+		}
+		h.listening.Store(false)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pgRetryDelay):
+		}
+	}
+}
+
+func (h *PostgresHub) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, h.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+pgChannel); err != nil {
+		return err
+	}
+	h.listening.Store(true)
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var m pgMessage
+		if err := json.Unmarshal([]byte(n.Payload), &m); err != nil {
+			log.Printf("share events: bad notification: %v", err)
+			continue
+		}
+		h.local.Publish(m.event())
+	}
+}
+
+// sendQueued sends the queued events until ctx is done. Events that cannot be sent are delivered
+// locally.
+func (h *PostgresHub) sendQueued(ctx context.Context) {
+	defer func() {
+		if h.notify != nil {
+			h.notify.Close(context.Background())
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-h.queue:
+			if err := h.send(ev); err != nil {
+				log.Printf("share events: notify: %v", err)
+				h.local.Publish(ev)
+			}
+		}
+	}
+}
+
+// send notifies all listeners of ev on a connection kept for publishing.
+func (h *PostgresHub) send(ev Event) error {
+	m := pgMessage{
+		Type:      ev.Type,
+		ShareID:   ev.ShareID,
+		VersionID: ev.VersionID,
+		ActorSub:  ev.ActorSub,
+		At:        ev.At.UnixNano(),
+	}
+	if len(ev.Name) <= pgMaxName {
+		m.Name = ev.Name
+	}
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pgNotifyTimeout)
+	defer cancel()
+
+	if h.notify == nil || h.notify.IsClosed() {
+		if h.notify, err = pgx.Connect(ctx, h.dsn); err != nil {
+			h.notify = nil
+			return err
+		}
+	}
+	if _, err := h.notify.Exec(ctx, "SELECT pg_notify($1, $2)", pgChannel, string(payload)); err != nil {
+		h.notify.Close(context.Background())
+		h.notify = nil
+		return err
+	}
+	return nil
+}
+
+// pgMessage is an event as sent through Postgres.
+type pgMessage struct {
+	Type      string `json:"t"`
+	ShareID   string `json:"s"`
+	VersionID string `json:"v,omitempty"`
+	ActorSub  string `json:"a,omitempty"`
+	Name      string `json:"n,omitempty"`
+	At        int64  `json:"at"`
+}
+
+func (m pgMessage) event() Event {
+	return Event{Type: m.Type, ShareID: m.ShareID, VersionID: m.VersionID, ActorSub: m.ActorSub, Name: m.Name, At: time.Unix(0, m.At)}
+}
@@ -0,0 +1,146 @@
+// Package export turns a schema into tables for spreadsheets: CSV, or XLSX written without any
+// third-party dependency.
+package export
+
+import (
+	"sort"
+	"strings"
+
+	"eendraadschema-share-server/internal/schema"
+)
+
+// Circuit is one row of the circuit table. Fields that do not apply to the protection (e.g. a curve
+// on a smeltzekering) are left empty.
+type Circuit struct {
+	ItemID            int            `json:"itemId"`
+	Bord              string         `json:"bord"`
+	Naam              string         `json:"naam"`
+	Tekst             string         `json:"tekst"`
+	Bescherming       string         `json:"bescherming"`
+	CurveAutomaat     string         `json:"curveAutomaat"`
+	Amperage          string         `json:"amperage"`
+	AantalPolen       string         `json:"aantalPolen"`
+	DeltaAmperage     string         `json:"differentieelDeltaAmperage"`
+	TypeDifferentieel string         `json:"typeDifferentieel"`
+	TypeKabel         string         `json:"typeKabel"`
+	Items             map[string]int `json:"items"`
+}
+
+// layoutTypes only structure the drawing and are not counted as connected items.
+var layoutTypes = map[string]bool{"": true, "Kring": true, "Splitsing": true, "Vrije tekst": true}
+
+// Circuits returns one row per attached Kring, in schema order. Items counts the attached items
+// whose own kring is this one, per type; nested kringen are rows of their own and are not counted.
+func Circuits(doc *schema.Document) []Circuit {
+	out := []Circuit{}
+	rows := map[*schema.Item]int{}
+	for i := range doc.Data {
+		k := &doc.Data[i]
+		if k.Type() != "Kring" || !doc.Attached(k.ID) {
+			continue
+		}
+		p := k.Props
+		bescherming := strings.TrimSpace(p.String("bescherming"))
+		c := Circuit{
+			ItemID:      k.ID,
+			Bord:        bordName(doc, k),
+			Naam:        clean(p.String("naam")),
+			Tekst:       clean(p.String("tekst")),
+			Bescherming: bescherming,
+			Items:       map[string]int{},
+		}
+		if bescherming != "geen" {
+			c.Amperage = clean(p.String("amperage"))
+			c.AantalPolen = clean(p.String("aantal_polen"))
+		}
+		switch bescherming {
+		case "automatisch":
+			c.CurveAutomaat = clean(p.String("curve_automaat"))
+		case "differentieel":
+			c.DeltaAmperage = clean(p.String("differentieel_delta_amperage"))
+			c.TypeDifferentieel = clean(p.String("type_differentieel"))
+		case "differentieelautomaat":
+			c.CurveAutomaat = clean(p.String("curve_automaat"))
+			c.DeltaAmperage = clean(p.String("differentieel_delta_amperage"))
+			c.TypeDifferentieel = clean(p.String("type_differentieel"))
+		}
+		if _, ok := p["kabel_is_aanwezig"]; !ok || p.Bool("kabel_is_aanwezig") {
+			c.TypeKabel = clean(p.String("type_kabel"))
+		}
+		rows[k] = len(out)
+		out = append(out, c)
+	}
+
+	for i := range doc.Data {
+		it := &doc.Data[i]
+		if layoutTypes[it.Type()] || !doc.Attached(it.ID) {
+			continue
+		}
+		if row, ok := rows[doc.KringOf(it)]; ok {
+			out[row].Items[it.Type()]++
+		}
+	}
+	return out
+}
+
+// ItemTypes returns the item types counted in any of the circuits, sorted.
+func ItemTypes(circuits []Circuit) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, c := range circuits {
+		for t := range c.Items {
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return strings.ToLower(out[i]) < strings.ToLower(out[j]) })
+	return out
+}
+
+// CircuitSheet lays the circuits out as a sheet with one "Aantal ..." column per item type.
+func CircuitSheet(doc *schema.Document) *Sheet {
+	circuits := Circuits(doc)
+	types := ItemTypes(circuits)
+	s := &Sheet{
+		Name: "Kringen",
+		Header: []string{
+			"Bord", "Kring", "Omschrijving", "Bescherming", "Curve", "Amperage (A)", "Polen",
+			"Differentieel (mA)", "Type differentieel", "Kabel",
+		},
+	}
+	for _, t := range types {
+		s.Header = append(s.Header, "Aantal "+t)
+	}
+	for _, c := range circuits {
+		row := []any{
+			c.Bord, c.Naam, c.Tekst, c.Bescherming, c.CurveAutomaat, number(c.Amperage), number(c.AantalPolen),
+			number(c.DeltaAmperage), c.TypeDifferentieel, c.TypeKabel,
+		}
+		for _, t := range types {
+			row = append(row, c.Items[t])
+		}
+		s.Rows = append(s.Rows, row)
+	}
+	return s
+}
+
+// bordName returns the name of the nearest Bord above the kring.
+func bordName(doc *schema.Document, k *schema.Item) string {
+	for depth, p := 0, doc.ParentOf(k); p != nil && depth < len(doc.Data); depth, p = depth+1, doc.ParentOf(p) {
+		if p.Type() == "Bord" {
+			return clean(p.Props.String("naam"))
+		}
+	}
+	return ""
+}
+
+// clean trims a prop and drops the "---" placeholder the frontend uses for empty fields.
+func clean(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "---" {
+		return ""
+	}
+	return s
+}
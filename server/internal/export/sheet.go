@@ -0,0 +1,63 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Sheet is a single table. Cells are strings, ints or float64s; numbers stay numeric in XLSX.
+type Sheet struct {
+	Name   string
+	Header []string
+	Rows   [][]any
+}
+
+// WriteCSV writes the header and rows as comma-separated values.
+func (s *Sheet) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(s.Header); err != nil {
+		return err
+	}
+	for _, row := range s.Rows {
+		rec := make([]string, len(row))
+		for i, v := range row {
+			rec[i] = cellText(v)
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// number returns a prop value as float64 when it is a plain number (with a dot or a Belgian
+// decimal comma), so that spreadsheets can sum it, and the original text otherwise.
+func number(s string) any {
+	if s == "" {
+		return s
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+	if err != nil {
+		return s
+	}
+	return f
+}
+
+func cellText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,351 @@
+// Package lint checks a decoded schema against common rules of the Belgian AREI/RGIE.
+// Rules only look at what the schema records; they are a checklist for the installer, not a
+// substitute for the inspection.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"eendraadschema-share-server/internal/schema"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Finding is a single rule violation. ItemID is the item the frontend should highlight.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	ItemID   int      `json:"itemId"`
+	Kring    string   `json:"kring,omitempty"`
+	Message  string   `json:"message"`
+}
+
+type Rule struct {
+	ID    string
+	Check func(doc *schema.Document) []Finding
+}
+
+// Rules are run in this order by Run.
+var Rules = []Rule{
+	{"kring.max-sockets", checkMaxSockets},
+	{"kring.cable-section", checkCableSection},
+	{"kring.socket-section", checkSocketSection},
+	{"kring.wet-room-rcd", checkWetRoomRCD},
+	{"kring.no-protection", checkNoProtection},
+	{"kring.unnamed", checkUnnamed},
+	{"contactdoos.earthing", checkEarthing},
+	{"contactdoos.child-safety", checkChildSafety},
+}
+
+// Run applies all rules and returns the findings, errors first and then by item id.
+func Run(doc *schema.Document) []Finding {
+	out := []Finding{}
+	for _, r := range Rules {
+		for _, f := range r.Check(doc) {
+			f.Rule = r.ID
+			out = append(out, f)
+		}
+	}
+	rank := map[Severity]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+	sort.SliceStable(out, func(i, j int) bool {
+		if rank[out[i].Severity] != rank[out[j].Severity] {
+			return rank[out[i].Severity] < rank[out[j].Severity]
+		}
+		return out[i].ItemID < out[j].ItemID
+	})
+	return out
+}
+
+// HasErrors reports whether any finding has error severity.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts returns the number of findings per severity.
+func Counts(findings []Finding) map[Severity]int {
+	out := map[Severity]int{SeverityError: 0, SeverityWarning: 0, SeverityInfo: 0}
+	for _, f := range findings {
+		out[f.Severity]++
+	}
+	return out
+}
+
+const maxSocketsPerKring = 8
+
+// maxAmperageBySection is the largest protection allowed per copper cross-section (mm²) in
+// domestic installations.
+var maxAmperageBySection = []struct {
+	section  float64
+	amperage float64
+}{
+	{1, 10}, {1.5, 16}, {2.5, 20}, {4, 32}, {6, 40}, {10, 50}, {16, 63}, {25, 80}, {35, 100},
+}
+
+// Wet rooms are recognised by the kring's naam or tekst; these appliances need a 30 mA
+// differential wherever they are.
+var (
+	wetRoomWords   = []string{"bad", "badkamer", "douche", "wasplaats", "washok", "wasruimte", "zwembad", "sauna"}
+	wetRoomDevices = map[string]bool{"Wasmachine": true, "Droogkast": true, "Vaatwasmachine": true}
+)
+
+// overcurrentProtections are the bescherming values that limit the current through the cable.
+var overcurrentProtections = map[string]bool{"automatisch": true, "differentieelautomaat": true, "smelt": true}
+
+// items returns the attached items of the given type, or all attached items for "".
+func items(doc *schema.Document, typ string) []*schema.Item {
+	var out []*schema.Item
+	for i := range doc.Data {
+		it := &doc.Data[i]
+		if (typ == "" || it.Type() == typ) && doc.Attached(it.ID) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func kringen(doc *schema.Document) []*schema.Item { return items(doc, "Kring") }
+
+// devicesOf returns the attached items of type typ whose own kring is k.
+func devicesOf(doc *schema.Document, k *schema.Item, typ string) []*schema.Item {
+	var out []*schema.Item
+	for _, it := range items(doc, typ) {
+		if doc.KringOf(it) == k {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func kringName(doc *schema.Document, k *schema.Item) string {
+	if n := strings.TrimSpace(k.Props.String("naam")); n != "" && n != "---" {
+		return n
+	}
+	return doc.FindKringName(k.ID)
+}
+
+func kringTitle(doc *schema.Document, k *schema.Item) string {
+	if n := kringName(doc, k); n != "" {
+		return "Kring " + n
+	}
+	return "Kring zonder naam"
+}
+
+var sectionPattern = regexp.MustCompile(`(\d+)\s*[GgXx×*]\s*(\d+(?:[.,]\d+)?)`)
+
+// cableSection extracts the conductor cross-section from a cable type like "XVB Cca 3G2,5".
+func cableSection(typeKabel string) (float64, bool) {
+	m := sectionPattern.FindAllStringSubmatch(typeKabel, -1)
+	if len(m) == 0 {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(m[len(m)-1][2], ",", "."), 64)
+	if err != nil || f <= 0 {
+		return 0, false
+	}
+	return f, true
+}
+
+func formatNum(f float64) string {
+	return strings.ReplaceAll(strconv.FormatFloat(f, 'f', -1, 64), ".", ",")
+}
+
+// kringCable returns the cross-section of the kring's cable, if it has one and it can be read.
+func kringCable(k *schema.Item) (float64, bool) {
+	if _, ok := k.Props["kabel_is_aanwezig"]; ok && !k.Props.Bool("kabel_is_aanwezig") {
+		return 0, false
+	}
+	return cableSection(k.Props.String("type_kabel"))
+}
+
+func checkMaxSockets(doc *schema.Document) []Finding {
+	var out []Finding
+	for _, k := range kringen(doc) {
+		// Double or triple sockets in one box count as a single socket outlet.
+		if n := len(devicesOf(doc, k, "Contactdoos")); n > maxSocketsPerKring {
+			out = append(out, Finding{Severity: SeverityError, ItemID: k.ID, Kring: kringName(doc, k),
+				Message: fmt.Sprintf("%s heeft %d contactdozen; het AREI laat er maximaal %d per kring toe.", kringTitle(doc, k), n, maxSocketsPerKring)})
+		}
+	}
+	return out
+}
+
+func checkCableSection(doc *schema.Document) []Finding {
+	var out []Finding
+	for _, k := range kringen(doc) {
+		if !overcurrentProtections[strings.TrimSpace(k.Props.String("bescherming"))] {
+			continue
+		}
+		amp, okAmp := k.Props.Float("amperage")
+		section, okSection := kringCable(k)
+		if !okAmp || !okSection {
+			continue
+		}
+		allowed := 0.0
+		for _, row := range maxAmperageBySection {
+			if section >= row.section {
+				allowed = row.amperage
+			}
+		}
+		if allowed == 0 || amp > allowed {
+			msg := fmt.Sprintf("%s: kabel van %s mm² is te dun voor een zekering van %s A", kringTitle(doc, k), formatNum(section), formatNum(amp))
+			if allowed > 0 {
+				msg += fmt.Sprintf(" (maximaal %s A).", formatNum(allowed))
+			} else {
+				msg += "."
+			}
+			out = append(out, Finding{Severity: SeverityError, ItemID: k.ID, Kring: kringName(doc, k), Message: msg})
+		}
+	}
+	return out
+}
+
+func checkSocketSection(doc *schema.Document) []Finding {
+	var out []Finding
+	for _, k := range kringen(doc) {
+		section, ok := kringCable(k)
+		if !ok || section >= 2.5 || len(devicesOf(doc, k, "Contactdoos")) == 0 {
+			continue
+		}
+		out = append(out, Finding{Severity: SeverityError, ItemID: k.ID, Kring: kringName(doc, k),
+			Message: fmt.Sprintf("%s voedt contactdozen met een kabel van %s mm²; dat moet minstens 2,5 mm² zijn.", kringTitle(doc, k), formatNum(section))})
+	}
+	return out
+}
+
+// rcdSensitivity returns the most sensitive differential (in mA) at or above the item.
+func rcdSensitivity(doc *schema.Document, it *schema.Item) (float64, bool) {
+	best, found := 0.0, false
+	for depth := 0; it != nil && depth <= len(doc.Data); depth, it = depth+1, doc.ParentOf(it) {
+		switch strings.TrimSpace(it.Props.String("bescherming")) {
+		case "differentieel", "differentieelautomaat":
+			if ma, ok := it.Props.Float("differentieel_delta_amperage"); ok && (!found || ma < best) {
+				best, found = ma, true
+			}
+		}
+	}
+	return best, found
+}
+
+func isWetRoom(k *schema.Item) bool {
+	text := strings.ToLower(k.Props.String("naam") + " " + k.Props.String("tekst"))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	})
+	for _, w := range words {
+		for _, wet := range wetRoomWords {
+			if w == wet {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkWetRoomRCD(doc *schema.Document) []Finding {
+	var out []Finding
+	flagged := map[int]bool{}
+	flag := func(it *schema.Item, k *schema.Item, reason string) {
+		if flagged[it.ID] {
+			return
+		}
+		if ma, ok := rcdSensitivity(doc, it); ok && ma <= 30 {
+			return
+		}
+		flagged[it.ID] = true
+		f := Finding{Severity: SeverityError, ItemID: it.ID, Message: reason + " moet beveiligd zijn door een differentieel van maximaal 30 mA."}
+		if k != nil {
+			f.Kring = kringName(doc, k)
+		}
+		out = append(out, f)
+	}
+	for _, k := range kringen(doc) {
+		if isWetRoom(k) {
+			flag(k, k, kringTitle(doc, k)+" (natte ruimte)")
+		}
+	}
+	for _, it := range items(doc, "") {
+		if !wetRoomDevices[it.Type()] {
+			continue
+		}
+		k := doc.KringOf(it)
+		if k == nil {
+			flag(it, nil, "Een "+strings.ToLower(it.Type()))
+			continue
+		}
+		if !isWetRoom(k) {
+			flag(k, k, kringTitle(doc, k)+" (met "+strings.ToLower(it.Type())+")")
+		}
+	}
+	return out
+}
+
+func checkNoProtection(doc *schema.Document) []Finding {
+	var out []Finding
+	for _, k := range kringen(doc) {
+		if p := doc.ParentOf(k); p == nil || p.Type() != "Bord" {
+			continue
+		}
+		if strings.TrimSpace(k.Props.String("bescherming")) == "geen" {
+			out = append(out, Finding{Severity: SeverityWarning, ItemID: k.ID, Kring: kringName(doc, k),
+				Message: kringTitle(doc, k) + " vertrekt uit het bord zonder zekering."})
+		}
+	}
+	return out
+}
+
+func checkUnnamed(doc *schema.Document) []Finding {
+	var out []Finding
+	for _, k := range kringen(doc) {
+		if p := doc.ParentOf(k); p == nil || p.Type() != "Bord" {
+			continue
+		}
+		if n := strings.TrimSpace(k.Props.String("naam")); n == "" || n == "---" {
+			out = append(out, Finding{Severity: SeverityInfo, ItemID: k.ID, Message: "Deze kring in het bord heeft nog geen naam."})
+		}
+	}
+	return out
+}
+
+// explicitlyFalse is true only when the prop is present and false, so that schemas that never set
+// it are not flagged.
+func explicitlyFalse(p schema.Props, key string) bool {
+	v, ok := p[key]
+	return ok && v != nil && !p.Bool(key)
+}
+
+func checkEarthing(doc *schema.Document) []Finding {
+	var out []Finding
+	for _, it := range items(doc, "Contactdoos") {
+		if explicitlyFalse(it.Props, "is_geaard") {
+			out = append(out, Finding{Severity: SeverityError, ItemID: it.ID, Kring: doc.FindKringName(it.ID),
+				Message: "Contactdoos zonder aarding; in een nieuwe installatie moeten alle contactdozen geaard zijn."})
+		}
+	}
+	return out
+}
+
+func checkChildSafety(doc *schema.Document) []Finding {
+	var out []Finding
+	for _, it := range items(doc, "Contactdoos") {
+		if explicitlyFalse(it.Props, "is_kinderveilig") {
+			out = append(out, Finding{Severity: SeverityWarning, ItemID: it.ID, Kring: doc.FindKringName(it.ID),
+				Message: "Contactdoos is niet kinderveilig."})
+		}
+	}
+	return out
+}
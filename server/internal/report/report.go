@@ -0,0 +1,276 @@
+// Package report renders the PDF circuit report of a share on the server: a cover page, the
+// circuit table, the bill of materials and the cable lengths.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"eendraadschema-share-server/internal/bom"
+	"eendraadschema-share-server/internal/export"
+	"eendraadschema-share-server/internal/schema"
+	"eendraadschema-share-server/internal/sitplan"
+)
+
+// A4 landscape, so the circuit table fits without wrapping.
+const (
+	pageWidth  = 841.89
+	pageHeight = 595.28
+	margin     = 40.0
+	bodySize   = 8.0
+	rowHeight  = 14.0
+)
+
+var months = []string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"}
+
+// Render returns the report as a PDF. updatedAt is shown in Belgian time when the zone database is
+// available, and in UTC otherwise.
+func Render(name string, updatedAt time.Time, doc *schema.Document) []byte {
+	l := &layout{}
+	circuits := export.Circuits(doc)
+
+	l.newPage()
+	if strings.TrimSpace(name) == "" {
+		name = "Naamloos schema"
+	}
+	l.page.text(margin, 170, regular, 14, "Eendraadschema")
+	l.page.text(margin, 205, bold, 28, fit(name, bold, 28, pageWidth-2*margin))
+	l.page.line(margin, 225, pageWidth-margin, 225, 1)
+	l.page.text(margin, 250, regular, 11, "Laatst gewijzigd op "+formatTime(updatedAt))
+	l.page.text(margin, 268, regular, 11, fmt.Sprintf("%d kringen", len(circuits)))
+
+	l.newPage()
+	l.heading("Kringen")
+	writeCircuits(l, circuits)
+
+	b := bom.Compute(doc)
+	l.heading("Materiaallijst")
+	if len(b.Devices) == 0 && len(b.Cables) == 0 {
+		l.paragraph("Er zijn geen materialen gevonden.")
+	}
+	writeLines(l, "Toestel", b.Devices)
+	writeLines(l, "Kabel", b.Cables)
+
+	l.heading("Kabellengtes")
+	writeCables(l, sitplan.Compute(doc))
+
+	for i, p := range l.doc.pages {
+		p.line(margin, pageHeight-30, pageWidth-margin, pageHeight-30, 0.5)
+		p.text(margin, pageHeight-18, regular, bodySize, fit(name, regular, bodySize, pageWidth/2))
+		p.textRight(pageWidth-margin, pageHeight-18, regular, bodySize, fmt.Sprintf("Pagina %d van %d", i+1, len(l.doc.pages)))
+	}
+	return l.doc.bytes()
+}
+
+func writeCircuits(l *layout, circuits []export.Circuit) {
+	if len(circuits) == 0 {
+		l.paragraph("Dit schema heeft geen kringen.")
+		return
+	}
+	types := export.ItemTypes(circuits)
+	cols := []column{
+		{title: "Bord", width: 60}, {title: "Kring", width: 45}, {title: "Omschrijving", width: 115},
+		{title: "Bescherming", width: 95}, {title: "Curve", width: 32}, {title: "A", width: 30, right: true},
+		{title: "Polen", width: 32, right: true}, {title: "mA", width: 32, right: true}, {title: "Type", width: 32},
+		{title: "Kabel", width: 95},
+	}
+	cols = append(cols, column{title: "Aangesloten", width: pageWidth - 2*margin - totalWidth(cols)})
+	var rows [][]string
+	for _, c := range circuits {
+		var connected []string
+		for _, t := range types {
+			if n := c.Items[t]; n > 0 {
+				connected = append(connected, fmt.Sprintf("%d× %s", n, t))
+			}
+		}
+		rows = append(rows, []string{
+			c.Bord, c.Naam, c.Tekst, c.Bescherming, c.CurveAutomaat, c.Amperage, c.AantalPolen,
+			c.DeltaAmperage, c.TypeDifferentieel, c.TypeKabel, strings.Join(connected, ", "),
+		})
+	}
+	l.table(cols, rows)
+}
+
+func writeLines(l *layout, title string, lines []bom.Line) {
+	if len(lines) == 0 {
+		return
+	}
+	rows := make([][]string, len(lines))
+	for i, ln := range lines {
+		unit := "st."
+		if ln.Unit == bom.UnitMeters {
+			unit = "m"
+		}
+		rows[i] = []string{ln.Label, formatNum(ln.Quantity), unit}
+	}
+	l.table([]column{{title: title, width: 400}, {title: "Aantal", width: 60, right: true}, {title: "", width: 30}}, rows)
+}
+
+func writeCables(l *layout, rep *sitplan.Report) {
+	if len(rep.Kringen) == 0 {
+		l.paragraph("Het situatieschema bevat geen kabeltrajecten.")
+		return
+	}
+	cols := []column{{title: "Kring", width: 250}, {title: "Kabel", width: 150}, {title: "Lengte (m)", width: 60, right: true}}
+	var rows [][]string
+	for _, spec := range sortedSpecs(rep.MetersBySpec) {
+		rows = append(rows, []string{"Totaal", spec, formatNum(rep.MetersBySpec[spec])})
+	}
+	for _, k := range rep.Kringen {
+		for _, spec := range sortedSpecs(k.MetersBySpec) {
+			rows = append(rows, []string{k.Label, spec, formatNum(k.MetersBySpec[spec])})
+		}
+	}
+	l.table(cols, rows)
+
+	if len(rep.Floors) > 1 {
+		rows = nil
+		for _, f := range rep.Floors {
+			name := f.Name
+			if name == "" {
+				name = "Zonder verdieping"
+			}
+			for _, spec := range sortedSpecs(f.MetersBySpec) {
+				rows = append(rows, []string{name, spec, formatNum(f.MetersBySpec[spec])})
+			}
+		}
+		if len(rows) > 0 {
+			l.table([]column{{title: "Verdieping", width: 250}, cols[1], cols[2]}, rows)
+		}
+	}
+
+	var missing []string
+	if n := rep.UnknownScaleRuns; n > 0 {
+		missing = append(missing, fmt.Sprintf("%d run(s) zonder schaal", n))
+	}
+	if n := rep.UnknownRiserCount; n > 0 {
+		missing = append(missing, fmt.Sprintf("%d stijgleiding(en) zonder hoogte", n))
+	}
+	if n := rep.UnknownEndpointCount; n > 0 {
+		missing = append(missing, fmt.Sprintf("%d eindpunt(en) zonder hoogte", n))
+	}
+	if len(missing) > 0 {
+		l.paragraph("Onvolledig: " + strings.Join(missing, ", ") + ". De werkelijke lengte is groter.")
+	}
+	l.paragraph("Stijgleidingen tussen verdiepingen tellen mee per kring, niet per verdieping.")
+}
+
+func sortedSpecs(m map[string]float64) []string {
+	out := make([]string, 0, len(m))
+	for spec := range m {
+		out = append(out, spec)
+	}
+	sort.Slice(out, func(i, j int) bool { return strings.ToLower(out[i]) < strings.ToLower(out[j]) })
+	return out
+}
+
+func formatNum(f float64) string {
+	return strings.ReplaceAll(strconv.FormatFloat(f, 'f', -1, 64), ".", ",")
+}
+
+func formatTime(t time.Time) string {
+	if loc, err := time.LoadLocation("Europe/Brussels"); err == nil {
+		t = t.In(loc)
+	} else {
+		t = t.UTC()
+	}
+	zone, _ := t.Zone()
+	return fmt.Sprintf("%d %s %d om %02d:%02d (%s)", t.Day(), months[t.Month()-1], t.Year(), t.Hour(), t.Minute(), zone)
+}
+
+// layout keeps a cursor on the current page and starts a new page when content does not fit.
+type layout struct {
+	doc  pdfDoc
+	page *pdfPage
+	y    float64
+}
+
+type column struct {
+	title string
+	width float64
+	right bool
+}
+
+func totalWidth(cols []column) float64 {
+	w := 0.0
+	for _, c := range cols {
+		w += c.width
+	}
+	return w
+}
+
+func (l *layout) newPage() {
+	l.page = l.doc.addPage(pageWidth, pageHeight)
+	l.y = margin
+}
+
+// ensure starts a new page unless h more points fit above the footer.
+func (l *layout) ensure(h float64) bool {
+	if l.y+h <= pageHeight-45 {
+		return false
+	}
+	l.newPage()
+	return true
+}
+
+func (l *layout) heading(s string) {
+	if l.y > margin {
+		l.y += 12
+	}
+	// Keep a heading together with at least the first rows below it.
+	l.ensure(24 + 3*rowHeight)
+	l.y += 16
+	l.page.text(margin, l.y, bold, 14, s)
+	l.y += 10
+}
+
+func (l *layout) paragraph(s string) {
+	l.ensure(rowHeight)
+	l.y += rowHeight
+	l.page.text(margin, l.y, regular, bodySize+1, fit(s, regular, bodySize+1, pageWidth-2*margin))
+}
+
+// table draws the rows below a grey header, repeating the header on every page it spans.
+func (l *layout) table(cols []column, rows [][]string) {
+	width := totalWidth(cols)
+	header := func() {
+		l.page.fillRect(margin, l.y, width, rowHeight, 0.88)
+		l.drawRow(cols, nil, bold)
+	}
+	l.y += 6
+	l.ensure(2 * rowHeight)
+	header()
+	for _, row := range rows {
+		if l.ensure(rowHeight) {
+			header()
+		}
+		l.drawRow(cols, row, regular)
+		l.page.line(margin, l.y, margin+width, l.y, 0.25)
+	}
+}
+
+// drawRow draws one row of cells, or the column titles when cells is nil, and moves the cursor below it.
+func (l *layout) drawRow(cols []column, cells []string, font pdfFont) {
+	x := margin
+	baseline := l.y + rowHeight - 4
+	for i, c := range cols {
+		s := c.title
+		if cells != nil {
+			s = ""
+			if i < len(cells) {
+				s = cells[i]
+			}
+		}
+		s = fit(s, font, bodySize, c.width-6)
+		if c.right {
+			l.page.textRight(x+c.width-3, baseline, font, bodySize, s)
+		} else {
+			l.page.text(x+3, baseline, font, bodySize, s)
+		}
+		x += c.width
+	}
+	l.y += rowHeight
+}
@@ -0,0 +1,121 @@
+package retention
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"eendraadschema-share-server/internal/config"
+	"eendraadschema-share-server/internal/store"
+)
+
+// Job prunes share versions by policy, and beyond the configured maximum count, at an interval.
+type Job struct {
+	store       *store.Store
+	policy      Policy
+	maxVersions int
+	interval    time.Duration
+}
+
+func New(cfg config.Config, st *store.Store) (*Job, error) {
+	p, err := Parse(cfg.VersionRetention)
+	if err != nil {
+		return nil, err
+	}
+	return &Job{store: st, policy: p, maxVersions: cfg.ShareVersionsMax, interval: cfg.RetentionInterval}, nil
+}
+
+// Run prunes once right away and then every interval until ctx is done. It does nothing when the
+// interval is not positive.
+func (j *Job) Run(ctx context.Context) {
+	if j.interval <= 0 {
+		return
+	}
+	t := time.NewTicker(j.interval)
+	defer t.Stop()
+	for {
+		if n, err := j.RunOnce(ctx, time.Now()); err != nil {
+			log.Printf("version retention: %v", err)
+		} else if n > 0 {
+			log.Printf("version retention: deleted %d versions", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RunOnce prunes the versions of every share and then sweeps unreferenced schema blobs. It returns
+// the number of versions deleted.
+func (j *Job) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	deleted := 0
+	after := ""
+	for {
+		targets, err := j.store.ListShareRetentionTargets(ctx, after, 200)
+		if err != nil {
+			return deleted, err
+		}
+		if len(targets) == 0 {
+			break
+		}
+		for _, t := range targets {
+			after = t.ShareID
+			n, err := j.prune(ctx, t, now)
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+	}
+	if _, err := j.store.CollectSchemaBlobs(ctx, now); err != nil {
+		return deleted, err
+	}
+	return deleted, nil
+}
+
+func (j *Job) prune(ctx context.Context, t store.ShareRetentionTarget, now time.Time) (int, error) {
+	p := j.policy
+	if t.RetentionPolicy != "" {
+		// Policies are validated when they are set; fall back to the default if one is not.
+		if tp, err := Parse(t.RetentionPolicy); err == nil {
+			p = tp
+		}
+	}
+	stamps, err := j.store.ListShareVersionStamps(ctx, t.ShareID)
+	if err != nil {
+		return 0, err
+	}
+	// Tagged versions, versions with an unresolved comment thread, the head and the version under
+	// review are always kept and don't count against the policy or the maximum.
+	candidates := make([]store.ShareVersionStamp, 0, len(stamps))
+	for _, s := range stamps {
+		if !s.Tagged && !s.Anchored && s.ID != t.HeadVersionID && s.ID != t.ReviewVersionID {
+			candidates = append(candidates, s)
+		}
+	}
+	created := make([]time.Time, len(candidates))
+	for i, s := range candidates {
+		created[i] = s.CreatedAt
+	}
+	drop := map[int]bool{}
+	for _, i := range p.Expired(created, now) {
+		drop[i] = true
+	}
+	if j.maxVersions > 0 {
+		for i := j.maxVersions; i < len(candidates); i++ {
+			drop[i] = true
+		}
+	}
+	if len(drop) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, 0, len(drop))
+	for i, s := range candidates {
+		if drop[i] {
+			ids = append(ids, s.ID)
+		}
+	}
+	return len(ids), j.store.DeleteShareVersions(ctx, t.ShareID, ids)
+}
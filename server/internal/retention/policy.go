@@ -0,0 +1,160 @@
+// Package retention decides which share versions to keep over time and prunes the rest in the
+// background.
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy keeps every version younger than KeepAll, then the newest version per Every-long window
+// for as long as the first tier whose For covers its age. Older versions are dropped. The newest
+// version of a share is always kept. The zero Policy keeps everything.
+type Policy struct {
+	KeepAll time.Duration
+	Tiers   []Tier
+}
+
+type Tier struct {
+	Every time.Duration
+	For   time.Duration
+}
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+	year = 365 * day
+)
+
+// Parse reads a policy written as a comma-separated list: an optional bare duration for the
+// keep-all window, then every:for pairs. "24h,1d:30d,1w:1y" keeps all versions from the last 24
+// hours, one per day for 30 days and one per week for a year. Besides Go durations, the units d, w
+// and y (365 days) are accepted. An empty string or "all" is the zero Policy.
+func Parse(s string) (Policy, error) {
+	var p Policy
+	if strings.EqualFold(strings.TrimSpace(s), "all") {
+		return p, nil
+	}
+	for i, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		every, span, isTier := strings.Cut(part, ":")
+		if !isTier {
+			if i != 0 {
+				return Policy{}, fmt.Errorf("retention: keep-all window %q must come first", part)
+			}
+			d, err := parseDuration(part)
+			if err != nil {
+				return Policy{}, err
+			}
+			p.KeepAll = d
+			continue
+		}
+		e, err := parseDuration(every)
+		if err != nil {
+			return Policy{}, err
+		}
+		f, err := parseDuration(span)
+		if err != nil {
+			return Policy{}, err
+		}
+		p.Tiers = append(p.Tiers, Tier{Every: e, For: f})
+	}
+	sort.SliceStable(p.Tiers, func(i, j int) bool { return p.Tiers[i].For < p.Tiers[j].For })
+	return p, nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	units := map[byte]time.Duration{'d': day, 'w': week, 'y': year}
+	if n := len(s); n > 1 {
+		if unit, ok := units[s[n-1]]; ok {
+			v, err := strconv.Atoi(s[:n-1])
+			if err == nil && v > 0 {
+				return time.Duration(v) * unit, nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("retention: invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// String formats the policy the way Parse reads it.
+func (p Policy) String() string {
+	var parts []string
+	if p.KeepAll > 0 {
+		parts = append(parts, formatDuration(p.KeepAll))
+	}
+	for _, t := range p.Tiers {
+		parts = append(parts, formatDuration(t.Every)+":"+formatDuration(t.For))
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatDuration(d time.Duration) string {
+	for _, u := range []struct {
+		unit time.Duration
+		name string
+	}{{year, "y"}, {week, "w"}, {day, "d"}, {time.Hour, "h"}, {time.Minute, "m"}} {
+		if d%u.unit == 0 {
+			return strconv.FormatInt(int64(d/u.unit), 10) + u.name
+		}
+	}
+	return d.String()
+}
+
+func (p Policy) IsZero() bool { return p.KeepAll == 0 && len(p.Tiers) == 0 }
+
+// Expired returns the indexes of the versions the policy drops. created must be sorted newest
+// first. Windows are aligned to the Unix epoch, so "one per day" means one per UTC day.
+func (p Policy) Expired(created []time.Time, now time.Time) []int {
+	if p.IsZero() {
+		return nil
+	}
+	type window struct {
+		tier int
+		n    int64
+	}
+	seen := map[window]bool{}
+	var out []int
+	for i, t := range created {
+		age := now.Sub(t)
+		if i == 0 || age <= p.KeepAll {
+			continue
+		}
+		tier := -1
+		for j, tr := range p.Tiers {
+			if age <= tr.For {
+				tier = j
+				break
+			}
+		}
+		if tier < 0 {
+			out = append(out, i)
+			continue
+		}
+		w := window{tier: tier, n: floorDiv(t.UnixNano(), int64(p.Tiers[tier].Every))}
+		if seen[w] {
+			out = append(out, i)
+			continue
+		}
+		seen[w] = true
+	}
+	return out
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,41 @@
+package retention
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPolicyExpired(t *testing.T) {
+	p, err := Parse("24h,1d:30d,1w:1y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+	at := func(day, hour int) time.Time { return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		created []time.Time
+		want    []int
+	}{
+		{"newest is kept however old", p, []time.Time{ago(2 * year)}, nil},
+		{"all within 24h", p, []time.Time{now, ago(time.Hour), ago(time.Hour), ago(24 * time.Hour)}, nil},
+		{"just past 24h is one per day", p, []time.Time{now, ago(24 * time.Hour), ago(24*time.Hour + time.Second), ago(24*time.Hour + 2*time.Second)}, []int{3}},
+		{"one per UTC day", p, []time.Time{now, at(14, 10), at(14, 8), at(14, 0), at(13, 23)}, []int{2, 3}},
+		{"day tier up to 30d", p, []time.Time{now, ago(30 * day), ago(30*day + time.Second)}, nil},
+		{"week tier past 30d", p, []time.Time{now, ago(30*day + time.Second), ago(30*day + 2*time.Second), ago(31 * day)}, []int{2, 3}},
+		{"week tier up to 1y", p, []time.Time{now, ago(year)}, nil},
+		{"dropped past 1y", p, []time.Time{now, ago(year - week), ago(year + time.Second), ago(2 * year)}, []int{2, 3}},
+		{"all keeps everything", Policy{}, []time.Time{now, ago(time.Hour), ago(time.Hour), ago(2 * year)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Expired(tt.created, now); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Expired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSummaryLen keeps summaries short enough for the version picker.
+const maxSummaryLen = 280
+
+// typeLabels holds the Dutch singular and plural used in summaries. Types not listed here are
+// shown with their type name.
+var typeLabels = map[string][2]string{
+	"Aansluiting":            {"aansluiting", "aansluitingen"},
+	"Aftakdoos":              {"aftakdoos", "aftakdozen"},
+	"Bord":                   {"bord", "borden"},
+	"Contactdoos":            {"stopcontact", "stopcontacten"},
+	"Drukknop":               {"drukknop", "drukknoppen"},
+	"Kring":                  {"kring", "kringen"},
+	"Leiding":                {"leiding", "leidingen"},
+	"Lichtpunt":              {"lichtpunt", "lichtpunten"},
+	"Schakelaars":            {"schakelaar", "schakelaars"},
+	"Splitsing":              {"splitsing", "splitsingen"},
+	"Verbruiker":             {"verbruiker", "verbruikers"},
+	"Vrije tekst":            {"tekst", "teksten"},
+	"Zekering/differentieel": {"zekering", "zekeringen"},
+	"Zonnepaneel":            {"zonnepaneel", "zonnepanelen"},
+	"Omvormer":               {"omvormer", "omvormers"},
+	"Batterij":               {"batterij", "batterijen"},
+	"Boiler":                 {"boiler", "boilers"},
+	"Domotica":               {"domotica module", "domotica modules"},
+	"Wasmachine":             {"wasmachine", "wasmachines"},
+	"Droogkast":              {"droogkast", "droogkasten"},
+	"Koelkast":               {"koelkast", "koelkasten"},
+	"Diepvriezer":            {"diepvriezer", "diepvriezers"},
+	"Ventilator":             {"ventilator", "ventilatoren"},
+	"Warmtepomp":             {"warmtepomp", "warmtepompen"},
+	"Vrije ruimte":           {"vrije ruimte", "vrije ruimtes"},
+}
+
+// kringProps describes the Kring props that get their own phrase; unit is appended to both values.
+var kringProps = []struct {
+	key, label, unit string
+}{
+	{"naam", "naam", ""},
+	{"bescherming", "bescherming", ""},
+	{"amperage", "zekering", "A"},
+	{"differentieel_delta_amperage", "differentieel", "mA"},
+	{"type_differentieel", "type differentieel", ""},
+	{"curve_automaat", "curve", ""},
+	{"aantal_polen", "polen", ""},
+	{"type_kabel", "kabel", ""},
+}
+
+// Summarize describes in Dutch what changed from prev to next, grouped per kring, e.g.
+// "Kring B: zekering 16A → 20A, 2 stopcontacten toegevoegd". prev is nil for the first version.
+func Summarize(prev, next *Document) string {
+	if next == nil {
+		return ""
+	}
+	if prev == nil {
+		return "Eerste versie"
+	}
+	d := Compare(prev, next)
+	if d.Empty() {
+		return "Geen wijzigingen"
+	}
+
+	type group struct {
+		label   string
+		phrases []string
+		counts  map[string]map[string]int // status -> type -> count
+	}
+	groups := map[string]*group{}
+	var order []string
+	groupFor := func(label string) *group {
+		g, ok := groups[label]
+		if !ok {
+			g = &group{label: label, counts: map[string]map[string]int{}}
+			groups[label] = g
+			order = append(order, label)
+		}
+		return g
+	}
+
+	for _, it := range d.SortedItems() {
+		doc := next
+		if it.Status == StatusRemoved {
+			doc = prev
+		}
+		item, _ := doc.ItemByID(it.ID)
+		if it.Type == "Kring" && len(it.Props) > 0 {
+			g := groupFor(kringLabel(doc, item))
+			g.phrases = append(g.phrases, kringPhrases(it.Props)...)
+		}
+		if it.Type == "Kring" && it.Status == StatusChanged {
+			continue
+		}
+		// Kringen themselves are listed under their parent's group when added, removed or moved.
+		g := groupFor(kringLabel(doc, doc.ParentOf(item)))
+		if it.Type == "Kring" {
+			name := ""
+			if item != nil && item.Name() != "" {
+				name = " " + item.Name()
+			}
+			g.phrases = append(g.phrases, "kring"+name+" "+statusVerb(it.Status))
+			continue
+		}
+		n := 1
+		if it.Type == "Contactdoos" && it.Status != StatusChanged && item != nil {
+			if v, ok := item.Props.Int("aantal"); ok && v > 1 {
+				n = v
+			}
+		}
+		if g.counts[it.Status] == nil {
+			g.counts[it.Status] = map[string]int{}
+		}
+		g.counts[it.Status][it.Type] += n
+	}
+
+	var parts []string
+	for _, label := range order {
+		g := groups[label]
+		phrases := g.phrases
+		for _, status := range []string{StatusAdded, StatusRemoved, StatusMoved, StatusChanged} {
+			types := make([]string, 0, len(g.counts[status]))
+			for t := range g.counts[status] {
+				types = append(types, t)
+			}
+			sort.Strings(types)
+			for _, t := range types {
+				phrases = append(phrases, countLabel(g.counts[status][t], t)+" "+statusVerb(status))
+			}
+		}
+		if len(phrases) == 0 {
+			continue
+		}
+		text := strings.Join(phrases, ", ")
+		if label != "" {
+			text = label + ": " + text
+		} else {
+			text = strings.ToUpper(text[:1]) + text[1:]
+		}
+		parts = append(parts, text)
+	}
+	if len(d.Properties) > 0 {
+		parts = append(parts, "Algemene gegevens gewijzigd")
+	}
+	if len(d.SitPlan) > 0 {
+		parts = append(parts, sitPlanPhrase(d.SitPlan))
+	}
+
+	out := strings.Join(parts, "; ")
+	if r := []rune(out); len(r) > maxSummaryLen {
+		out = string(r[:maxSummaryLen-1]) + "…"
+	}
+	return out
+}
+
+// kringLabel returns "Kring <naam>" for the nearest named kring at or above it, or "" when there is none.
+func kringLabel(doc *Document, it *Item) string {
+	for depth := 0; it != nil && depth < len(doc.Data); depth++ {
+		if it.Type() == "Kring" && it.Name() != "" {
+			return "Kring " + it.Name()
+		}
+		it = doc.ParentOf(it)
+	}
+	return ""
+}
+
+func kringPhrases(props map[string]PropChange) []string {
+	var out []string
+	done := map[string]bool{}
+	for _, p := range kringProps {
+		c, ok := props[p.key]
+		if !ok {
+			continue
+		}
+		done[p.key] = true
+		from, to := propText(c.From), propText(c.To)
+		if from == "" {
+			from = "-"
+		} else {
+			from += p.unit
+		}
+		if to == "" {
+			to = "-"
+		} else {
+			to += p.unit
+		}
+		out = append(out, fmt.Sprintf("%s %s → %s", p.label, from, to))
+	}
+	if len(done) < len(props) {
+		out = append(out, "eigenschappen gewijzigd")
+	}
+	return out
+}
+
+func propText(v any) string {
+	return strings.TrimSpace(Props{"v": v}.String("v"))
+}
+
+func countLabel(n int, typ string) string {
+	l, ok := typeLabels[typ]
+	if !ok {
+		if typ == "" {
+			typ = "element"
+		}
+		l = [2]string{strings.ToLower(typ), strings.ToLower(typ)}
+	}
+	if n == 1 {
+		return "1 " + l[0]
+	}
+	return fmt.Sprintf("%d %s", n, l[1])
+}
+
+func statusVerb(status string) string {
+	switch status {
+	case StatusAdded:
+		return "toegevoegd"
+	case StatusRemoved:
+		return "verwijderd"
+	case StatusMoved:
+		return "verplaatst"
+	default:
+		return "gewijzigd"
+	}
+}
+
+func sitPlanPhrase(els []ElementDiff) string {
+	counts := map[string]int{}
+	for _, el := range els {
+		counts[el.Status]++
+	}
+	var phrases []string
+	for _, status := range []string{StatusAdded, StatusRemoved, StatusMoved} {
+		switch n := counts[status]; n {
+		case 0:
+		case 1:
+			phrases = append(phrases, "1 symbool "+statusVerb(status))
+		default:
+			phrases = append(phrases, fmt.Sprintf("%d symbolen %s", n, statusVerb(status)))
+		}
+	}
+	return "Situatieschema: " + strings.Join(phrases, ", ")
+}
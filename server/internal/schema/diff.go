@@ -0,0 +1,252 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	StatusAdded   = "added"
+	StatusRemoved = "removed"
+	StatusMoved   = "moved"
+	StatusChanged = "changed"
+)
+
+// Diff is the structural difference between two decoded schemas.
+type Diff struct {
+	// Items is keyed by item id. Only active items are compared.
+	Items      map[int]*ItemDiff     `json:"items"`
+	Properties map[string]PropChange `json:"properties,omitempty"`
+	SitPlan    []ElementDiff         `json:"sitplan"`
+}
+
+type ItemDiff struct {
+	ID     int    `json:"id"`
+	Type   string `json:"type"`
+	Name   string `json:"name,omitempty"`
+	Status string `json:"status"`
+	// Parent is the parent in the newest version the item appears in.
+	Parent     int                   `json:"parent"`
+	FromParent *int                  `json:"fromParent,omitempty"`
+	Props      map[string]PropChange `json:"props,omitempty"`
+}
+
+type PropChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// ElementDiff describes a situation plan element that was added, removed or moved.
+// Key identifies the element across versions, e.g. "item:12", "connectionPoint:R1@2" or "cableRun#2".
+type ElementDiff struct {
+	Key           string    `json:"key"`
+	Kind          string    `json:"kind"`
+	ElectroItemID *int      `json:"electroItemId,omitempty"`
+	Status        string    `json:"status"`
+	From          *Position `json:"from,omitempty"`
+	To            *Position `json:"to,omitempty"`
+}
+
+type Position struct {
+	Page int     `json:"page"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+}
+
+// Counts returns the number of items per status.
+func (d *Diff) Counts() map[string]int {
+	out := map[string]int{StatusAdded: 0, StatusRemoved: 0, StatusMoved: 0, StatusChanged: 0}
+	for _, it := range d.Items {
+		out[it.Status]++
+	}
+	return out
+}
+
+// Empty reports whether nothing differs.
+func (d *Diff) Empty() bool {
+	return len(d.Items) == 0 && len(d.Properties) == 0 && len(d.SitPlan) == 0
+}
+
+// SortedItems returns the item diffs ordered by id.
+func (d *Diff) SortedItems() []*ItemDiff {
+	out := make([]*ItemDiff, 0, len(d.Items))
+	for _, it := range d.Items {
+		out = append(out, it)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Compare computes the structural diff from a to b.
+func Compare(a, b *Document) *Diff {
+	d := &Diff{Items: map[int]*ItemDiff{}}
+
+	// Sibling order is compared only among items that keep the same parent, so that inserting
+	// or deleting a sibling does not flag everything after it as moved.
+	stays := func(id int) bool {
+		na, okA := a.ItemByID(id)
+		nb, okB := b.ItemByID(id)
+		return okA && okB && a.IsActive(id) && b.IsActive(id) && na.Parent == nb.Parent
+	}
+	aPos := siblingPositions(a, stays)
+	bPos := siblingPositions(b, stays)
+	for i := range b.Data {
+		nb := &b.Data[i]
+		if !b.Active[i] {
+			continue
+		}
+		if !a.IsActive(nb.ID) {
+			d.Items[nb.ID] = &ItemDiff{ID: nb.ID, Type: nb.Type(), Name: nb.Name(), Status: StatusAdded, Parent: nb.Parent}
+			continue
+		}
+		na, _ := a.ItemByID(nb.ID)
+		changes := compareProps(na.Props, nb.Props)
+		status := ""
+		if na.Parent != nb.Parent || aPos[na.ID] != bPos[nb.ID] {
+			status = StatusMoved
+		} else if len(changes) > 0 {
+			status = StatusChanged
+		}
+		if status == "" {
+			continue
+		}
+		it := &ItemDiff{ID: nb.ID, Type: nb.Type(), Name: nb.Name(), Status: status, Parent: nb.Parent, Props: changes}
+		if na.Parent != nb.Parent {
+			from := na.Parent
+			it.FromParent = &from
+		}
+		d.Items[nb.ID] = it
+	}
+	for i := range a.Data {
+		na := &a.Data[i]
+		if a.Active[i] && !b.IsActive(na.ID) {
+			d.Items[na.ID] = &ItemDiff{ID: na.ID, Type: na.Type(), Name: na.Name(), Status: StatusRemoved, Parent: na.Parent}
+		}
+	}
+
+	d.Properties = compareProps(propertiesMap(a.Properties), propertiesMap(b.Properties))
+	d.SitPlan = compareSitPlans(a.SitPlan, b.SitPlan)
+	return d
+}
+
+// siblingPositions returns, per active item id accepted by keep, its index among those siblings.
+func siblingPositions(doc *Document, keep func(id int) bool) map[int]int {
+	count := map[int]int{}
+	out := make(map[int]int, len(doc.Data))
+	for i, it := range doc.Data {
+		if !doc.Active[i] || !keep(it.ID) {
+			continue
+		}
+		out[it.ID] = count[it.Parent]
+		count[it.Parent]++
+	}
+	return out
+}
+
+// compareProps compares string renderings so that e.g. "20" and 20 are considered equal,
+// and a missing prop equals an empty one.
+func compareProps(a, b Props) map[string]PropChange {
+	var out map[string]PropChange
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	for k := range keys {
+		if a.String(k) == b.String(k) {
+			continue
+		}
+		if out == nil {
+			out = map[string]PropChange{}
+		}
+		out[k] = PropChange{From: a[k], To: b[k]}
+	}
+	return out
+}
+
+func propertiesMap(p Properties) Props {
+	return Props{
+		"filename":  p.Filename,
+		"owner":     p.Owner,
+		"installer": p.Installer,
+		"control":   p.Control,
+		"info":      p.Info,
+	}
+}
+
+type keyedElement struct {
+	key string
+	el  *SitPlanElement
+}
+
+// elementKeys assigns stable keys to sitplan elements, which carry no persistent id of their own.
+// Symbols are keyed by the item they depict, connection points by their riser id, and everything
+// else by its ordinal within its kind.
+func elementKeys(sp *SitPlan) []keyedElement {
+	if sp == nil {
+		return nil
+	}
+	seen := map[string]int{}
+	out := make([]keyedElement, 0, len(sp.Elements))
+	for i := range sp.Elements {
+		el := &sp.Elements[i]
+		kind := el.EffectiveKind()
+		base := kind
+		switch {
+		case kind == "default" && el.ElectroItemID != nil:
+			base = fmt.Sprintf("item:%d", *el.ElectroItemID)
+		case kind == "connectionPoint" && el.ConnectionPoint != nil:
+			base = fmt.Sprintf("connectionPoint:%s@%d", el.ConnectionPoint.ConnectionID, el.Page)
+		}
+		seen[base]++
+		key := base
+		if seen[base] > 1 || (base == kind) {
+			key = fmt.Sprintf("%s#%d", base, seen[base])
+		}
+		out = append(out, keyedElement{key: key, el: el})
+	}
+	return out
+}
+
+func compareSitPlans(a, b *SitPlan) []ElementDiff {
+	out := []ElementDiff{}
+	aEls := elementKeys(a)
+	aByKey := make(map[string]*SitPlanElement, len(aEls))
+	for _, ke := range aEls {
+		aByKey[ke.key] = ke.el
+	}
+	bKeys := map[string]bool{}
+	for _, ke := range elementKeys(b) {
+		bKeys[ke.key] = true
+		prev, ok := aByKey[ke.key]
+		if !ok {
+			out = append(out, elementDiff(ke.key, ke.el, StatusAdded, nil, ke.el))
+			continue
+		}
+		if prev.Page != ke.el.Page || moved(prev.PosX, ke.el.PosX) || moved(prev.PosY, ke.el.PosY) {
+			out = append(out, elementDiff(ke.key, ke.el, StatusMoved, prev, ke.el))
+		}
+	}
+	for _, ke := range aEls {
+		if !bKeys[ke.key] {
+			out = append(out, elementDiff(ke.key, ke.el, StatusRemoved, ke.el, nil))
+		}
+	}
+	return out
+}
+
+func elementDiff(key string, el *SitPlanElement, status string, from, to *SitPlanElement) ElementDiff {
+	pos := func(e *SitPlanElement) *Position {
+		if e == nil {
+			return nil
+		}
+		return &Position{Page: e.Page, X: float64(e.PosX), Y: float64(e.PosY)}
+	}
+	return ElementDiff{Key: key, Kind: el.EffectiveKind(), ElectroItemID: el.ElectroItemID, Status: status, From: pos(from), To: pos(to)}
+}
+
+// moved ignores sub-pixel jitter from dragging.
+func moved(a, b Num) bool { return math.Abs(float64(a-b)) >= 0.5 }
@@ -0,0 +1,171 @@
+package schema
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CurrentVersion is the schema version written by the current frontend ("EDS0040000" / "TXT0040000").
+const CurrentVersion = 4
+
+// headerLen is the length of the "EDSvvv0000" / "TXTvvv0000" header.
+const headerLen = 10
+
+// maxInflatedBytes caps the decompressed size of an EDS payload to guard against zip bombs.
+const maxInflatedBytes = 256 << 20
+
+type Format string
+
+const (
+	FormatEDS Format = "EDS" // base64(zlib(json))
+	FormatTXT Format = "TXT" // plain json
+)
+
+// Envelope is a schema string split into its header and raw JSON payload.
+type Envelope struct {
+	Format  Format
+	Version int
+	JSON    []byte
+}
+
+// Open parses the header of a schema string and returns the decompressed JSON payload.
+// It mirrors EDStoJson in src/importExport/importExport.ts.
+func Open(text string) (Envelope, error) {
+	if len(text) < headerLen {
+		return Envelope{}, invalidf("schema is too short (%d bytes)", len(text))
+	}
+	format := Format(text[:3])
+	if format != FormatEDS && format != FormatTXT {
+		return Envelope{}, invalidf("schema must start with EDS or TXT")
+	}
+	version, err := strconv.Atoi(text[3:6])
+	if err != nil || version <= 0 {
+		return Envelope{}, invalidf("schema header has invalid version %q", text[3:6])
+	}
+	if text[6:headerLen] != "0000" {
+		return Envelope{}, invalidf("schema header has invalid padding %q", text[6:headerLen])
+	}
+	env := Envelope{Format: format, Version: version}
+
+	payload := text[headerLen:]
+	switch format {
+	case FormatTXT:
+		env.JSON = []byte(payload)
+	case FormatEDS:
+		raw, err := decodeBase64(payload)
+		if err != nil {
+			return Envelope{}, invalidf("EDS payload is not valid base64: %v", err)
+		}
+		inflated, err := inflate(raw)
+		if err != nil {
+			return Envelope{}, invalidf("EDS payload could not be decompressed: %v", err)
+		}
+		env.JSON = inflated
+	}
+	if len(bytes.TrimSpace(env.JSON)) == 0 {
+		return Envelope{}, invalidf("schema payload is empty")
+	}
+	return env, nil
+}
+
+// Normalize opens a schema and compacts its JSON payload, so that the same schema saved as EDS or
+// TXT, compressed differently or indented differently, gives the same envelope.
+func Normalize(text string) (Envelope, error) {
+	env, err := Open(text)
+	if err != nil {
+		return Envelope{}, err
+	}
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, env.JSON); err == nil {
+		env.JSON = payload.Bytes()
+	} else {
+		env.JSON = bytes.TrimSpace(env.JSON)
+	}
+	return env, nil
+}
+
+// Hash returns the hex SHA-256 of a schema's normalised content: its format, version and compacted
+// JSON payload (see Normalize). The format is included so that the EDS and TXT encodings of a
+// schema are stored apart and each is read back as written. Text that cannot be opened is hashed
+// as is.
+func Hash(text string) string {
+	env, err := Normalize(text)
+	if err != nil {
+		sum := sha256.Sum256([]byte(text))
+		return hex.EncodeToString(sum[:])
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s%03d:", env.Format, env.Version)
+	h.Write(env.JSON)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Seal encodes a JSON payload in the given format using the current header layout.
+func Seal(format Format, version int, jsonText []byte) (string, error) {
+	if version <= 0 || version > 999 {
+		return "", fmt.Errorf("invalid schema version %d", version)
+	}
+	header := fmt.Sprintf("%s%03d0000", format, version)
+	switch format {
+	case FormatTXT:
+		return header + string(jsonText), nil
+	case FormatEDS:
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		if _, err := zw.Write(jsonText); err != nil {
+			return "", err
+		}
+		if err := zw.Close(); err != nil {
+			return "", err
+		}
+		return header + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+	default:
+		return "", fmt.Errorf("unsupported schema format %q", format)
+	}
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, s)
+	// The share link variant uses base64url; accept both, with or without padding.
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// inflate accepts both zlib-wrapped (pako.deflate) and raw deflate (pako.deflateRaw) streams.
+func inflate(raw []byte) ([]byte, error) {
+	if zr, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+		defer zr.Close()
+		return readLimited(zr)
+	}
+	fr := flate.NewReader(bytes.NewReader(raw))
+	defer fr.Close()
+	return readLimited(fr)
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	out, err := io.ReadAll(io.LimitReader(r, maxInflatedBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxInflatedBytes {
+		return nil, fmt.Errorf("payload exceeds %d bytes", maxInflatedBytes)
+	}
+	return out, nil
+}
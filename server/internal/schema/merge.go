@@ -0,0 +1,541 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// ConflictEdited: both sides changed the same fields of an item to different values.
+	ConflictEdited = "edited"
+	// ConflictDeleted: one side removed an item the other side changed, or added or moved items
+	// under.
+	ConflictDeleted = "edited_and_deleted"
+	// ConflictDocument: both sides changed the same document field, e.g. a property or the
+	// situation plan.
+	ConflictDocument = "document"
+	// ConflictOrder: both sides reordered the same items differently.
+	ConflictOrder = "order"
+)
+
+// MergeConflict is a change made on both sides that Merge cannot combine.
+type MergeConflict struct {
+	Reason string `json:"reason"`
+	ItemID int    `json:"itemId,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Name   string `json:"name,omitempty"`
+	// Fields lists the conflicting item fields and props, or the document fields.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// MergeResult is the outcome of Merge: the merged schema, or the conflicts that prevented it.
+type MergeResult struct {
+	Schema    string
+	Conflicts []MergeConflict
+	// Renumbered maps ids of items added on the incoming side that clashed with items added on
+	// the head side to the new ids they were given.
+	Renumbered map[int]int
+}
+
+// Merge combines two schemas derived from base: head (already stored) and incoming (being saved).
+// Items are matched by id and merged per field and per prop; document properties per property;
+// the situation plan and any other top-level field as a whole. Items both sides added with the
+// same id are kept apart by renumbering the incoming ones. The merged schema uses the format of
+// incoming and the current version.
+func Merge(base, head, incoming string) (MergeResult, error) {
+	b, err := openRaw(base)
+	if err != nil {
+		return MergeResult{}, fmt.Errorf("base: %w", err)
+	}
+	h, err := openRaw(head)
+	if err != nil {
+		return MergeResult{}, fmt.Errorf("head: %w", err)
+	}
+	in, err := openRaw(incoming)
+	if err != nil {
+		return MergeResult{}, fmt.Errorf("incoming: %w", err)
+	}
+
+	res := MergeResult{Renumbered: renumberClashes(b, h, in)}
+	merged := rawDoc{top: map[string]any{}, items: map[int]rawItem{}}
+
+	ids := map[int]bool{}
+	for _, d := range []*rawDoc{b, h, in} {
+		for id := range d.items {
+			ids[id] = true
+		}
+	}
+	for _, id := range sortedKeys(ids) {
+		bi, bok := b.items[id]
+		hi, hok := h.items[id]
+		ii, iok := in.items[id]
+		switch {
+		case sameItem(hi, hok, bi, bok):
+			if iok {
+				merged.items[id] = ii
+			}
+		case sameItem(ii, iok, bi, bok), sameItem(hi, hok, ii, iok):
+			if hok {
+				merged.items[id] = hi
+			}
+		case !hok || !iok:
+			kept := hi
+			if iok {
+				kept = ii
+			}
+			res.Conflicts = append(res.Conflicts, itemConflict(ConflictDeleted, id, kept, nil))
+		default:
+			item, fields := mergeItem(bi, hi, ii)
+			if len(fields) > 0 {
+				res.Conflicts = append(res.Conflicts, itemConflict(ConflictEdited, id, hi, fields))
+				continue
+			}
+			merged.items[id] = item
+		}
+	}
+
+	for _, c := range detachedConflicts(b, h, in, merged.items, sortedKeys(ids)) {
+		if !slices.ContainsFunc(res.Conflicts, func(o MergeConflict) bool { return o.ItemID == c.ItemID }) {
+			res.Conflicts = append(res.Conflicts, c)
+		}
+	}
+
+	order, ok := mergeOrder(b, h, in, merged.items)
+	if !ok {
+		res.Conflicts = append(res.Conflicts, MergeConflict{Reason: ConflictOrder})
+	}
+	merged.order = order
+
+	var docFields []string
+	keys := map[string]bool{}
+	for _, d := range []*rawDoc{b, h, in} {
+		for k := range d.top {
+			keys[k] = true
+		}
+	}
+	for _, k := range sortedKeys(keys) {
+		switch k {
+		case "data", "active", "id", "length", "curid":
+			continue
+		case "properties":
+			props, conflicts := mergeObject(asObject(b.top[k]), asObject(h.top[k]), asObject(in.top[k]))
+			for _, c := range conflicts {
+				docFields = append(docFields, "properties."+c)
+			}
+			merged.top[k] = props
+			continue
+		}
+		v, ok, conflict := merge3(b.top[k], h.top[k], in.top[k], hasKey(b.top, k), hasKey(h.top, k), hasKey(in.top, k))
+		if conflict {
+			docFields = append(docFields, k)
+			continue
+		}
+		if ok {
+			merged.top[k] = v
+		}
+	}
+	if len(docFields) > 0 {
+		res.Conflicts = append(res.Conflicts, MergeConflict{Reason: ConflictDocument, Fields: docFields})
+	}
+	if len(res.Conflicts) > 0 {
+		return res, nil
+	}
+
+	curID := max(topInt(h.top, "curid"), topInt(in.top, "curid"))
+	for id := range merged.items {
+		curID = max(curID, id)
+	}
+	payload, err := merged.encode(curID)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	if _, err := DecodeEnvelope(Envelope{Format: in.format, Version: CurrentVersion, JSON: payload}); err != nil {
+		return MergeResult{}, fmt.Errorf("merged schema: %w", err)
+	}
+	if res.Schema, err = Seal(in.format, CurrentVersion, payload); err != nil {
+		return MergeResult{}, err
+	}
+	return res, nil
+}
+
+// rawDoc is a schema payload decoded generically, so that props and fields the server does not
+// model survive a merge unchanged.
+type rawDoc struct {
+	format Format
+	top    map[string]any
+	order  []int
+	items  map[int]rawItem
+}
+
+type rawItem struct {
+	obj    map[string]any
+	active bool
+}
+
+// openRaw opens a schema, upgrading older versions first, and indexes its items by id.
+func openRaw(text string) (*rawDoc, error) {
+	env, err := Open(text)
+	if err != nil {
+		return nil, err
+	}
+	if env.Version < CurrentVersion {
+		upgraded, _, err := Upgrade(text)
+		if err != nil {
+			return nil, err
+		}
+		if env, err = Open(upgraded); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := DecodeEnvelope(env); err != nil {
+		return nil, err
+	}
+	d := &rawDoc{format: env.Format, items: map[int]rawItem{}}
+	dec := json.NewDecoder(bytes.NewReader(env.JSON))
+	dec.UseNumber()
+	if err := dec.Decode(&d.top); err != nil {
+		return nil, invalidf("payload is not valid json: %v", err)
+	}
+	data, _ := d.top["data"].([]any)
+	active, _ := d.top["active"].([]any)
+	for i, raw := range data {
+		obj, _ := raw.(map[string]any)
+		id := toInt(obj["id"])
+		isActive, _ := active[i].(bool)
+		d.items[id] = rawItem{obj: obj, active: isActive}
+		d.order = append(d.order, id)
+	}
+	return d, nil
+}
+
+func (d *rawDoc) encode(curID int) ([]byte, error) {
+	data := make([]any, 0, len(d.order))
+	active := make([]any, 0, len(d.order))
+	ids := make([]any, 0, len(d.order))
+	for _, id := range d.order {
+		it := d.items[id]
+		data = append(data, it.obj)
+		active = append(active, it.active)
+		ids = append(ids, id)
+	}
+	d.top["data"] = data
+	d.top["active"] = active
+	d.top["id"] = ids
+	d.top["length"] = len(data)
+	d.top["curid"] = curID
+	return json.Marshal(d.top)
+}
+
+// renumberClashes gives items that head and incoming both added under the same id, with different
+// content, a fresh id on the incoming side, and updates the references to them there.
+func renumberClashes(b, h, in *rawDoc) map[int]int {
+	next := max(topInt(h.top, "curid"), topInt(in.top, "curid"))
+	for _, d := range []*rawDoc{b, h, in} {
+		for id := range d.items {
+			next = max(next, id)
+		}
+	}
+	remap := map[int]int{}
+	for _, id := range in.order {
+		hi, hok := h.items[id]
+		_, bok := b.items[id]
+		if bok || !hok || sameItem(hi, true, in.items[id], true) {
+			continue
+		}
+		next++
+		remap[id] = next
+	}
+	if len(remap) == 0 {
+		return nil
+	}
+	items := make(map[int]rawItem, len(in.items))
+	for id, it := range in.items {
+		obj := cloneObject(it.obj)
+		if n, ok := remap[id]; ok {
+			obj["id"] = n
+			id = n
+		}
+		if n, ok := remap[toInt(obj["parent"])]; ok {
+			obj["parent"] = n
+		}
+		items[id] = rawItem{obj: obj, active: it.active}
+	}
+	in.items = items
+	for i, id := range in.order {
+		if n, ok := remap[id]; ok {
+			in.order[i] = n
+		}
+	}
+	// Situation plan symbols refer to the item they depict.
+	if sp, ok := in.top["sitplanjson"].(map[string]any); ok {
+		els, _ := sp["elements"].([]any)
+		for _, raw := range els {
+			el, ok := raw.(map[string]any)
+			if !ok || el["electroItemId"] == nil {
+				continue
+			}
+			if n, ok := remap[toInt(el["electroItemId"])]; ok {
+				el["electroItemId"] = n
+			}
+		}
+	}
+	return remap
+}
+
+// detachedConflicts reports merged items that one side added, moved or edited and that are attached
+// to the tree on that side but not in the merged result, e.g. a child added under a Kring the other
+// side deleted. The frontend silently drops such items, so the ancestor that went missing is
+// reported as deleted, once. An item whose merged parents form a cycle is reported as edited.
+func detachedConflicts(b, h, in *rawDoc, merged map[int]rawItem, ids []int) []MergeConflict {
+	var out []MergeConflict
+	reported := map[int]bool{}
+	for _, id := range ids {
+		it, ok := merged[id]
+		if !ok || !it.active {
+			continue
+		}
+		missing, attached := ancestry(merged, id)
+		if attached || reported[missing] {
+			continue
+		}
+		// Only items a side added, moved or edited are lost; the subtree of an item one side
+		// deleted is meant to go.
+		changed := false
+		for _, d := range []*rawDoc{h, in} {
+			sideItem, ok := d.items[id]
+			bi, bok := b.items[id]
+			if _, onSide := ancestry(d.items, id); ok && onSide && !sameItem(sideItem, true, bi, bok) {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		reported[missing] = true
+		if missing == id {
+			out = append(out, itemConflict(ConflictEdited, id, it, []string{"parent"}))
+			continue
+		}
+		parent, ok := merged[missing]
+		for _, d := range []*rawDoc{h, in, b} {
+			if !ok {
+				parent, ok = d.items[missing]
+			}
+		}
+		out = append(out, itemConflict(ConflictDeleted, missing, parent, nil))
+	}
+	return out
+}
+
+// ancestry follows the parents of item id. It reports whether the item and all its ancestors are
+// present and active, like Document.Attached, and otherwise the first one that is not, or id
+// itself when the parents form a cycle.
+func ancestry(items map[int]rawItem, id int) (int, bool) {
+	start := id
+	for range len(items) + 1 {
+		it, ok := items[id]
+		if !ok || !it.active {
+			return id, false
+		}
+		parent := toInt(it.obj["parent"])
+		if parent == 0 {
+			return 0, true
+		}
+		id = parent
+	}
+	return start, false
+}
+
+// mergeItem merges an item changed on both sides, field by field and prop by prop. "active" is
+// treated as a field. It returns the conflicting fields, with props as "props.<name>".
+func mergeItem(b, h, in rawItem) (rawItem, []string) {
+	wrap := func(it rawItem) map[string]any {
+		obj := cloneObject(it.obj)
+		delete(obj, "props")
+		obj["active"] = it.active
+		return obj
+	}
+	fields, conflicts := mergeObject(wrap(b), wrap(h), wrap(in))
+	props, propConflicts := mergeObject(asObject(b.obj["props"]), asObject(h.obj["props"]), asObject(in.obj["props"]))
+	for _, c := range propConflicts {
+		conflicts = append(conflicts, "props."+c)
+	}
+	if len(conflicts) > 0 {
+		return rawItem{}, conflicts
+	}
+	active, _ := fields["active"].(bool)
+	delete(fields, "active")
+	fields["props"] = props
+	return rawItem{obj: fields, active: active}, nil
+}
+
+// mergeObject merges the keys of two objects derived from b. It returns the merged object and
+// the keys that both sides changed differently.
+func mergeObject(b, h, in map[string]any) (map[string]any, []string) {
+	out := map[string]any{}
+	keys := map[string]bool{}
+	for _, m := range []map[string]any{b, h, in} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	var conflicts []string
+	for _, k := range sortedKeys(keys) {
+		v, ok, conflict := merge3(b[k], h[k], in[k], hasKey(b, k), hasKey(h, k), hasKey(in, k))
+		if conflict {
+			conflicts = append(conflicts, k)
+			continue
+		}
+		if ok {
+			out[k] = v
+		}
+	}
+	return out, conflicts
+}
+
+// merge3 merges a single value that may be missing on any side. It returns the merged value, whether
+// it is present, and whether both sides changed it differently.
+func merge3(b, h, in any, bok, hok, iok bool) (any, bool, bool) {
+	switch {
+	case sameValue(h, hok, b, bok):
+		return in, iok, false
+	case sameValue(in, iok, b, bok), sameValue(h, hok, in, iok):
+		return h, hok, false
+	}
+	return nil, false, true
+}
+
+func sameValue(a any, aok bool, b any, bok bool) bool {
+	if aok != bok {
+		return false
+	}
+	return !aok || canonical(a) == canonical(b)
+}
+
+func sameItem(a rawItem, aok bool, b rawItem, bok bool) bool {
+	if aok != bok {
+		return false
+	}
+	return !aok || (a.active == b.active && canonical(a.obj) == canonical(b.obj))
+}
+
+// canonical renders a value as JSON with sorted keys, for comparison.
+func canonical(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// mergeOrder returns the list order of the merged items. The side that reordered the items
+// present everywhere provides the order (head when neither did), and items only the other side
+// has are inserted after the item they follow there. It returns false when both sides reordered.
+func mergeOrder(b, h, in *rawDoc, items map[int]rawItem) ([]int, bool) {
+	common := func(d *rawDoc) []int {
+		var out []int
+		for _, id := range d.order {
+			_, inB := b.items[id]
+			_, inH := h.items[id]
+			_, inI := in.items[id]
+			if inB && inH && inI {
+				out = append(out, id)
+			}
+		}
+		return out
+	}
+	bSeq, hSeq, iSeq := common(b), common(h), common(in)
+	skeleton, other := h, in
+	if slices.Equal(hSeq, bSeq) {
+		skeleton, other = in, h
+	}
+	ok := slices.Equal(hSeq, bSeq) || slices.Equal(iSeq, bSeq) || slices.Equal(hSeq, iSeq)
+
+	var order []int
+	placed := map[int]bool{}
+	for _, id := range skeleton.order {
+		if _, keep := items[id]; keep {
+			order = append(order, id)
+			placed[id] = true
+		}
+	}
+	prev := -1
+	for _, id := range other.order {
+		if _, keep := items[id]; !keep {
+			continue
+		}
+		if !placed[id] {
+			pos := 0
+			if prev >= 0 {
+				pos = slices.Index(order, prev) + 1
+			}
+			order = slices.Insert(order, pos, id)
+			placed[id] = true
+		}
+		prev = id
+	}
+	return order, ok
+}
+
+func itemConflict(reason string, id int, it rawItem, fields []string) MergeConflict {
+	c := MergeConflict{Reason: reason, ItemID: id, Fields: fields}
+	if props := asObject(it.obj["props"]); props != nil {
+		p := Props(props)
+		c.Type = p.String("type")
+		c.Name = strings.TrimSpace(p.String("naam"))
+		if c.Name == "" {
+			c.Name = strings.TrimSpace(p.String("nr"))
+		}
+	}
+	return c
+}
+
+func asObject(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func cloneObject(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func hasKey(m map[string]any, k string) bool {
+	_, ok := m[k]
+	return ok
+}
+
+func topInt(m map[string]any, k string) int { return toInt(m[k]) }
+
+func toInt(v any) int {
+	switch n := v.(type) {
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			f, _ := n.Float64()
+			return int(f)
+		}
+		return int(i)
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
+
+func sortedKeys[K int | string](m map[K]bool) []K {
+	out := make([]K, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
@@ -0,0 +1,207 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type testItem struct {
+	id, parent int
+	props      map[string]any
+}
+
+func item(id, parent int, typ string, props ...any) testItem {
+	p := map[string]any{"type": typ}
+	for i := 0; i+1 < len(props); i += 2 {
+		p[props[i].(string)] = props[i+1]
+	}
+	return testItem{id: id, parent: parent, props: p}
+}
+
+// testSchema builds a TXT schema of the current version with the items in order, all active.
+func testSchema(t *testing.T, owner string, items ...testItem) string {
+	t.Helper()
+	data := make([]map[string]any, 0, len(items))
+	active := make([]bool, 0, len(items))
+	ids := make([]int, 0, len(items))
+	curID := 0
+	for _, it := range items {
+		data = append(data, map[string]any{"id": it.id, "parent": it.parent, "indent": 0, "collapsed": false, "props": it.props})
+		active = append(active, true)
+		ids = append(ids, it.id)
+		curID = max(curID, it.id)
+	}
+	payload, err := json.Marshal(map[string]any{
+		"length":     len(items),
+		"curid":      curID,
+		"data":       data,
+		"active":     active,
+		"id":         ids,
+		"properties": map[string]any{"owner": owner},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Seal(FormatTXT, CurrentVersion, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestMerge(t *testing.T) {
+	bord := item(1, 0, "Bord")
+	kring := item(2, 1, "Kring", "naam", "A")
+	base := []testItem{bord, kring, item(3, 2, "Stopcontact")}
+
+	type conflict struct {
+		Reason string
+		ItemID int
+		Fields []string
+	}
+	tests := []struct {
+		name       string
+		base       []testItem
+		head, in   []testItem
+		headOwner  string
+		inOwner    string
+		conflicts  []conflict
+		renumbered map[int]int
+		// items maps the ids expected in the merged schema to their parent and props.naam.
+		items map[int][2]any
+	}{
+		{
+			name:  "edit and add",
+			base:  base,
+			head:  []testItem{bord, item(2, 1, "Kring", "naam", "B"), item(3, 2, "Stopcontact")},
+			in:    []testItem{bord, kring, item(3, 2, "Stopcontact"), item(4, 2, "Lichtpunt")},
+			items: map[int][2]any{1: {0, nil}, 2: {1, "B"}, 3: {2, nil}, 4: {2, nil}},
+		},
+		{
+			name:  "different props of the same item",
+			base:  base,
+			head:  []testItem{bord, item(2, 1, "Kring", "naam", "B"), item(3, 2, "Stopcontact")},
+			in:    []testItem{bord, item(2, 1, "Kring", "naam", "A", "zekering", "C16"), item(3, 2, "Stopcontact")},
+			items: map[int][2]any{1: {0, nil}, 2: {1, "B"}, 3: {2, nil}},
+		},
+		{
+			name:  "same change on both sides",
+			base:  base,
+			head:  []testItem{bord, item(2, 1, "Kring", "naam", "B"), item(3, 2, "Stopcontact")},
+			in:    []testItem{bord, item(2, 1, "Kring", "naam", "B"), item(3, 2, "Stopcontact")},
+			items: map[int][2]any{1: {0, nil}, 2: {1, "B"}, 3: {2, nil}},
+		},
+		{
+			name:      "same prop of the same item",
+			base:      base,
+			head:      []testItem{bord, item(2, 1, "Kring", "naam", "B"), item(3, 2, "Stopcontact")},
+			in:        []testItem{bord, item(2, 1, "Kring", "naam", "C"), item(3, 2, "Stopcontact")},
+			conflicts: []conflict{{ConflictEdited, 2, []string{"props.naam"}}},
+		},
+		{
+			name:      "edited and deleted",
+			base:      base,
+			head:      []testItem{bord, kring},
+			in:        []testItem{bord, kring, item(3, 2, "Stopcontact", "naam", "x")},
+			conflicts: []conflict{{ConflictDeleted, 3, nil}},
+		},
+		{
+			name:       "both added the same id",
+			base:       base,
+			head:       []testItem{bord, kring, item(3, 2, "Stopcontact"), item(4, 2, "Lichtpunt")},
+			in:         []testItem{bord, kring, item(3, 2, "Stopcontact"), item(4, 2, "Schakelaar"), item(5, 4, "Lichtpunt")},
+			renumbered: map[int]int{4: 6},
+			items:      map[int][2]any{1: {0, nil}, 2: {1, nil}, 3: {2, nil}, 4: {2, nil}, 5: {6, nil}, 6: {2, nil}},
+		},
+		{
+			name:  "both added the same item",
+			base:  base,
+			head:  []testItem{bord, kring, item(3, 2, "Stopcontact"), item(4, 2, "Lichtpunt")},
+			in:    []testItem{bord, kring, item(3, 2, "Stopcontact"), item(4, 2, "Lichtpunt")},
+			items: map[int][2]any{1: {0, nil}, 2: {1, nil}, 3: {2, nil}, 4: {2, nil}},
+		},
+		{
+			name:      "added under a deleted item",
+			base:      base,
+			head:      []testItem{bord},
+			in:        []testItem{bord, kring, item(3, 2, "Stopcontact"), item(4, 3, "Lichtpunt")},
+			conflicts: []conflict{{ConflictDeleted, 3, nil}},
+		},
+		{
+			name:      "moved under a deleted item",
+			base:      []testItem{bord, kring, item(3, 1, "Kring", "naam", "B"), item(4, 2, "Stopcontact")},
+			head:      []testItem{bord, kring, item(4, 2, "Stopcontact")},
+			in:        []testItem{bord, kring, item(3, 1, "Kring", "naam", "B"), item(4, 3, "Stopcontact")},
+			conflicts: []conflict{{ConflictDeleted, 3, nil}},
+		},
+		{
+			name:  "subtree deleted on one side",
+			base:  base,
+			head:  []testItem{bord, item(3, 2, "Stopcontact")},
+			in:    base,
+			items: map[int][2]any{1: {0, nil}, 3: {2, nil}},
+		},
+		{
+			name:      "moves that form a cycle",
+			base:      []testItem{bord, kring, item(3, 1, "Kring", "naam", "B")},
+			head:      []testItem{bord, item(2, 3, "Kring", "naam", "A"), item(3, 1, "Kring", "naam", "B")},
+			in:        []testItem{bord, kring, item(3, 2, "Kring", "naam", "B")},
+			conflicts: []conflict{{ConflictEdited, 2, []string{"parent"}}, {ConflictEdited, 3, []string{"parent"}}},
+		},
+		{
+			name:      "document property",
+			base:      base,
+			head:      base,
+			in:        base,
+			headOwner: "Jan",
+			inOwner:   "Piet",
+			conflicts: []conflict{{ConflictDocument, 0, []string{"properties.owner"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Merge(testSchema(t, "", tt.base...), testSchema(t, tt.headOwner, tt.head...), testSchema(t, tt.inOwner, tt.in...))
+			if err != nil {
+				t.Fatalf("Merge: %v", err)
+			}
+			var got []conflict
+			for _, c := range res.Conflicts {
+				got = append(got, conflict{c.Reason, c.ItemID, c.Fields})
+			}
+			if !reflect.DeepEqual(got, tt.conflicts) {
+				t.Fatalf("conflicts = %+v, want %+v", got, tt.conflicts)
+			}
+			if len(res.Renumbered) > 0 || len(tt.renumbered) > 0 {
+				if !reflect.DeepEqual(res.Renumbered, tt.renumbered) {
+					t.Fatalf("renumbered = %v, want %v", res.Renumbered, tt.renumbered)
+				}
+			}
+			if len(tt.conflicts) > 0 {
+				if res.Schema != "" {
+					t.Fatal("schema returned with conflicts")
+				}
+				return
+			}
+			doc, err := Decode(res.Schema)
+			if err != nil {
+				t.Fatalf("merged schema: %v", err)
+			}
+			if len(doc.Data) != len(tt.items) {
+				t.Fatalf("merged schema has %d items, want %d", len(doc.Data), len(tt.items))
+			}
+			for id, want := range tt.items {
+				it, ok := doc.ItemByID(id)
+				if !ok {
+					t.Fatalf("item %d missing", id)
+				}
+				if it.Parent != want[0] {
+					t.Errorf("item %d parent = %d, want %d", id, it.Parent, want[0])
+				}
+				if want[1] != nil && it.Props.String("naam") != want[1] {
+					t.Errorf("item %d naam = %q, want %q", id, it.Props.String("naam"), want[1])
+				}
+			}
+		})
+	}
+}
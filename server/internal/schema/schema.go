@@ -0,0 +1,496 @@
+// Package schema decodes the share payloads produced by the frontend
+// (see src/importExport/importExport.ts) into a typed model.
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalid is wrapped by every error caused by a malformed schema payload.
+var ErrInvalid = errors.New("invalid schema")
+
+// ErrUnsupportedVersion is returned by Decode for well-formed payloads written by an older frontend.
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+func invalidf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+// Document is the decoded Hierarchical_List as written by toJsonObject().
+type Document struct {
+	Format  Format `json:"-"`
+	Version int    `json:"-"`
+
+	Length     int             `json:"length"`
+	CurID      int             `json:"curid"`
+	Data       []Item          `json:"data"`
+	Active     []bool          `json:"active"`
+	ID         []int           `json:"id"`
+	Properties Properties      `json:"properties"`
+	PrintTable json.RawMessage `json:"print_table,omitempty"`
+	SitPlan    *SitPlan        `json:"sitplanjson"`
+
+	byID map[int]int
+}
+
+type Properties struct {
+	Filename              string `json:"filename"`
+	Owner                 string `json:"owner"`
+	Installer             string `json:"installer"`
+	Control               string `json:"control"`
+	Info                  string `json:"info"`
+	DPI                   Num    `json:"dpi"`
+	DisableEDSCompression bool   `json:"disableEDSCompression"`
+	LegacySchakelaars     *bool  `json:"legacySchakelaars"`
+}
+
+// Item is a single List_Item. Its props are kept as a free-form object, like in the frontend.
+type Item struct {
+	ID        int   `json:"id"`
+	Parent    int   `json:"parent"`
+	Indent    int   `json:"indent"`
+	Collapsed bool  `json:"collapsed"`
+	Props     Props `json:"props"`
+}
+
+func (it Item) Type() string { return it.Props.String("type") }
+
+// Name returns the user-visible name of an item: its naam, falling back to its nr.
+func (it Item) Name() string {
+	if n := strings.TrimSpace(it.Props.String("naam")); n != "" {
+		return n
+	}
+	return strings.TrimSpace(it.Props.String("nr"))
+}
+
+type SitPlan struct {
+	NumPages          int              `json:"numPages"`
+	ActivePage        int              `json:"activePage"`
+	Defaults          map[string]any   `json:"defaults,omitempty"`
+	Floors            []Floor          `json:"floors"`
+	PageFloorIDs      []*string        `json:"pageFloorIds"`
+	PageMetersPerUnit []*Num           `json:"pageMetersPerUnit"`
+	Elements          []SitPlanElement `json:"elements"`
+}
+
+type Floor struct {
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	ElevationCm        *Num   `json:"elevationCm"`
+	CablePlaneOffsetCm *Num   `json:"cablePlaneOffsetCm"`
+}
+
+type SitPlanElement struct {
+	Kind            string           `json:"kind"`
+	DistanceLine    *DistanceLine    `json:"distanceLine,omitempty"`
+	CableRun        *CableRun        `json:"cableRun,omitempty"`
+	ConnectionPoint *ConnectionPoint `json:"connectionPoint,omitempty"`
+	Visible         *bool            `json:"visible"`
+	Page            int              `json:"page"`
+	HeightCm        *Num             `json:"heightCm"`
+	PosX            Num              `json:"posx"`
+	PosY            Num              `json:"posy"`
+	SizeX           Num              `json:"sizex"`
+	SizeY           Num              `json:"sizey"`
+	LabelPosX       Num              `json:"labelposx"`
+	LabelPosY       Num              `json:"labelposy"`
+	LabelFontSize   Num              `json:"labelfontsize"`
+	AdresType       *string          `json:"adrestype"`
+	Adres           *string          `json:"adres"`
+	AdresLocation   string           `json:"adreslocation"`
+	Rotate          Num              `json:"rotate"`
+	Scale           Num              `json:"scale"`
+	Movable         *bool            `json:"movable"`
+	SVG             string           `json:"svg"`
+	ElectroItemID   *int             `json:"electroItemId"`
+}
+
+// EffectiveKind returns the element kind, defaulting to "default" like fromJsonObject does.
+func (e SitPlanElement) EffectiveKind() string {
+	if strings.TrimSpace(e.Kind) == "" {
+		return "default"
+	}
+	return e.Kind
+}
+
+type Point struct {
+	X Num `json:"x"`
+	Y Num `json:"y"`
+}
+
+type DistanceLine struct {
+	X1             Num  `json:"x1"`
+	Y1             Num  `json:"y1"`
+	X2             Num  `json:"x2"`
+	Y2             Num  `json:"y2"`
+	DistanceCm     *Num `json:"distanceCm"`
+	DistanceMeters *Num `json:"distanceMeters,omitempty"`
+}
+
+type CableRun struct {
+	Points    []Point `json:"points"`
+	Kring     *string `json:"kring"`
+	CableSpec *string `json:"cableSpec"`
+}
+
+type ConnectionPoint struct {
+	ConnectionID string `json:"connectionId"`
+}
+
+// Decode parses a schema string (EDS0040000... or TXT0040000...) and validates its structure.
+// Payloads with an older version header return an error wrapping ErrUnsupportedVersion.
+func Decode(text string) (*Document, error) {
+	env, err := Open(text)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeEnvelope(env)
+}
+
+// DecodeAny decodes a schema of any supported version. Older versions are upgraded in memory first.
+func DecodeAny(text string) (*Document, error) {
+	env, err := Open(text)
+	if err != nil {
+		return nil, err
+	}
+	if env.Version < CurrentVersion {
+		upgraded, _, err := Upgrade(text)
+		if err != nil {
+			return nil, err
+		}
+		if env, err = Open(upgraded); err != nil {
+			return nil, err
+		}
+	}
+	return DecodeEnvelope(env)
+}
+
+// DecodeEnvelope decodes the JSON payload of an already opened envelope.
+func DecodeEnvelope(env Envelope) (*Document, error) {
+	if env.Version < CurrentVersion {
+		if !json.Valid(env.JSON) {
+			return nil, invalidf("payload is not valid json")
+		}
+		return nil, fmt.Errorf("%w: %03d (current is %03d)", ErrUnsupportedVersion, env.Version, CurrentVersion)
+	}
+	if env.Version > CurrentVersion {
+		return nil, invalidf("schema version %03d is newer than supported version %03d", env.Version, CurrentVersion)
+	}
+	doc, err := decodeJSON(env.JSON)
+	if err != nil {
+		return nil, err
+	}
+	doc.Format = env.Format
+	doc.Version = env.Version
+	return doc, nil
+}
+
+func decodeJSON(payload []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return nil, invalidf("field %s has wrong type (%s)", typeErr.Field, typeErr.Value)
+		}
+		return nil, invalidf("payload is not valid json: %v", err)
+	}
+	if doc.Data == nil {
+		return nil, invalidf("payload has no data array")
+	}
+	if err := doc.validate(); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (d *Document) validate() error {
+	n := len(d.Data)
+	if d.Length != n {
+		return invalidf("length is %d but data has %d items", d.Length, n)
+	}
+	if len(d.Active) != n {
+		return invalidf("active has %d entries, expected %d", len(d.Active), n)
+	}
+	if len(d.ID) != n {
+		return invalidf("id has %d entries, expected %d", len(d.ID), n)
+	}
+	d.byID = make(map[int]int, n)
+	for i, it := range d.Data {
+		if it.ID <= 0 {
+			return invalidf("data[%d].id must be positive, got %d", i, it.ID)
+		}
+		if it.ID != d.ID[i] {
+			return invalidf("data[%d].id is %d but id[%d] is %d", i, it.ID, i, d.ID[i])
+		}
+		if _, dup := d.byID[it.ID]; dup {
+			return invalidf("data[%d].id %d is not unique", i, it.ID)
+		}
+		if it.Props == nil {
+			return invalidf("data[%d] (id %d) has no props", i, it.ID)
+		}
+		if strings.TrimSpace(it.Type()) == "" {
+			return invalidf("data[%d] (id %d) has no props.type", i, it.ID)
+		}
+		d.byID[it.ID] = i
+	}
+	// Items whose parent no longer exists are tolerated: the frontend drops them in reSort().
+	// An item that is its own ancestor can never be placed in the tree.
+	done := make(map[int]bool, n)
+	for i, it := range d.Data {
+		if it.Parent == it.ID {
+			return invalidf("data[%d] (id %d) is its own parent", i, it.ID)
+		}
+		seen := map[int]bool{}
+		for id := it.ID; id != 0 && !done[id]; {
+			if seen[id] {
+				return invalidf("data[%d] (id %d) has a cycle in its parent chain", i, it.ID)
+			}
+			seen[id] = true
+			j, ok := d.byID[id]
+			if !ok {
+				break
+			}
+			id = d.Data[j].Parent
+		}
+		for id := range seen {
+			done[id] = true
+		}
+	}
+	if sp := d.SitPlan; sp != nil {
+		for i, f := range sp.Floors {
+			if strings.TrimSpace(f.ID) == "" {
+				return invalidf("sitplanjson.floors[%d] has no id", i)
+			}
+		}
+		for i, el := range sp.Elements {
+			switch el.EffectiveKind() {
+			case "default", "distanceLine", "cableRun", "connectionPoint":
+			default:
+				return invalidf("sitplanjson.elements[%d] has unknown kind %q", i, el.Kind)
+			}
+			if el.EffectiveKind() == "cableRun" && el.CableRun == nil {
+				return invalidf("sitplanjson.elements[%d] is a cableRun without cableRun data", i)
+			}
+		}
+	}
+	return nil
+}
+
+// ItemByID returns the item with the given id, active or not.
+func (d *Document) ItemByID(id int) (*Item, bool) {
+	i, ok := d.index()[id]
+	if !ok {
+		return nil, false
+	}
+	return &d.Data[i], true
+}
+
+// IsActive reports whether the item with the given id is active (not deleted).
+func (d *Document) IsActive(id int) bool {
+	i, ok := d.index()[id]
+	return ok && i < len(d.Active) && d.Active[i]
+}
+
+// Children returns the active direct children of the item with the given id (0 for root), in list order.
+func (d *Document) Children(parentID int) []*Item {
+	var out []*Item
+	for i := range d.Data {
+		if d.Data[i].Parent == parentID && d.Active[i] {
+			out = append(out, &d.Data[i])
+		}
+	}
+	return out
+}
+
+// ParentOf returns the parent item, or nil for root items.
+func (d *Document) ParentOf(it *Item) *Item {
+	if it == nil || it.Parent == 0 {
+		return nil
+	}
+	p, ok := d.ItemByID(it.Parent)
+	if !ok {
+		return nil
+	}
+	return p
+}
+
+// Attached reports whether the item is active and all its ancestors exist and are active.
+// The frontend silently drops items that are not attached when it rebuilds the tree.
+func (d *Document) Attached(id int) bool {
+	for depth := 0; depth <= len(d.Data); depth++ {
+		if !d.IsActive(id) {
+			return false
+		}
+		it, _ := d.ItemByID(id)
+		if it.Parent == 0 {
+			return true
+		}
+		id = it.Parent
+	}
+	return false
+}
+
+// KringOf returns the nearest Kring above the item, named or not, or nil if there is none.
+func (d *Document) KringOf(it *Item) *Item {
+	for depth, p := 0, d.ParentOf(it); p != nil && depth < len(d.Data); depth, p = depth+1, d.ParentOf(p) {
+		if p.Type() == "Kring" {
+			return p
+		}
+	}
+	return nil
+}
+
+// FindKringName returns the naam of the nearest named Kring above the item, or "" if there is none.
+// It mirrors findKringName in src/Hierarchical_List.ts.
+func (d *Document) FindKringName(id int) string {
+	it, ok := d.ItemByID(id)
+	for depth := 0; ok && depth < len(d.Data); depth++ {
+		p := d.ParentOf(it)
+		if p == nil {
+			return ""
+		}
+		if p.Type() == "Kring" {
+			if n := strings.TrimSpace(p.Props.String("naam")); n != "" {
+				return n
+			}
+		}
+		it = p
+	}
+	return ""
+}
+
+// KringLabels maps kring names to a more descriptive label, e.g. "B keuken" for kring B with tekst
+// "keuken", like ElectroItemZoeker.getKringLabel.
+func (d *Document) KringLabels() map[string]string {
+	out := map[string]string{}
+	for i, it := range d.Data {
+		if !d.Active[i] || it.Type() != "Kring" {
+			continue
+		}
+		naam := strings.TrimSpace(it.Props.String("naam"))
+		if naam == "" {
+			continue
+		}
+		tekst := strings.TrimSpace(it.Props.String("tekst"))
+		if tekst == "" || tekst == "---" {
+			out[naam] = naam
+		} else {
+			out[naam] = naam + " " + tekst
+		}
+	}
+	return out
+}
+
+func (d *Document) index() map[int]int {
+	if d.byID == nil {
+		d.byID = make(map[int]int, len(d.Data))
+		for i, it := range d.Data {
+			d.byID[it.ID] = i
+		}
+	}
+	return d.byID
+}
+
+// Num is a JSON number that also accepts numeric strings and null (as 0),
+// since the frontend does not always coerce values read from input fields.
+type Num float64
+
+func (n *Num) UnmarshalJSON(b []byte) error {
+	s := strings.TrimSpace(string(b))
+	if s == "null" {
+		*n = 0
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		var str string
+		if err := json.Unmarshal(b, &str); err != nil {
+			return err
+		}
+		str = strings.ReplaceAll(strings.TrimSpace(str), ",", ".")
+		if str == "" {
+			*n = 0
+			return nil
+		}
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return fmt.Errorf("not a number: %q", str)
+		}
+		*n = Num(f)
+		return nil
+	}
+	var f float64
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	*n = Num(f)
+	return nil
+}
+
+// Props is the free-form props object of an item. Values are usually strings,
+// numbers or booleans; the accessors below coerce between them like the frontend does.
+type Props map[string]any
+
+// String returns the prop as a string ("" when missing or null).
+func (p Props) String(key string) string {
+	switch v := p[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// Float parses the prop as a number, accepting Belgian decimal commas ("2,5").
+func (p Props) Float(key string) (float64, bool) {
+	switch v := p[key].(type) {
+	case float64:
+		return v, true
+	case string:
+		s := strings.ReplaceAll(strings.TrimSpace(v), ",", ".")
+		if s == "" {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, false
+		}
+		return f, true
+	default:
+		return 0, false
+	}
+}
+
+// Int parses the prop as an integer (truncating decimals).
+func (p Props) Int(key string) (int, bool) {
+	f, ok := p.Float(key)
+	if !ok {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// Bool returns true for boolean true and for the strings "true"/"1".
+func (p Props) Bool(key string) bool {
+	switch v := p[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return err == nil && b
+	case float64:
+		return v != 0
+	default:
+		return false
+	}
+}
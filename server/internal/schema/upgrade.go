@@ -0,0 +1,304 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// legacyKey maps a props field to its index in the key-based item format used by versions 1 and 2.
+type legacyKey struct {
+	prop string
+	idx  int
+}
+
+// Common key layouts, see convertLegacyKeys in src/List_Item/*.ts.
+var (
+	legacyNrAdres = []legacyKey{{"nr", 10}, {"adres", 15}}
+
+	legacyProtection = []legacyKey{
+		{"aantal_polen", 4}, {"bescherming", 7}, {"amperage", 8},
+		{"differentieel_delta_amperage", 11}, {"differentieel_is_selectief", 20}, {"kortsluitvermogen", 22},
+	}
+
+	legacySchakelaars = []legacyKey{
+		{"aantal_schakelaars", 4}, {"type_schakelaar", 5}, {"nr", 10}, {"adres", 15},
+		{"heeft_signalisatielampje", 19}, {"is_halfwaterdicht", 20}, {"heeft_verklikkerlampje", 21}, {"is_trekschakelaar", 25},
+	}
+)
+
+// legacyKeyMap lists the keys converted per item type. Types that did not exist in
+// the key-based era (Container, Leiding, Media, ...) only carry over their type.
+var legacyKeyMap = map[string][]legacyKey{
+	"Aansluiting": append([]legacyKey{
+		{"type_kabel_na_teller", 9}, {"nr", 10}, {"adres", 15}, {"naam", 23}, {"type_kabel_voor_teller", 24},
+	}, legacyProtection...),
+	"Aansluitpunt":        legacyNrAdres,
+	"Aardingsonderbreker": legacyNrAdres,
+	"Aftakdoos":           legacyNrAdres,
+	"Batterij":            legacyNrAdres,
+	"Bel":                 legacyNrAdres,
+	"Boiler":              {{"heeft_accumulatie", 3}, {"nr", 10}, {"adres", 15}},
+	"Bord":                {{"is_geaard", 1}, {"naam", 10}, {"adres", 15}},
+	"Contactdoos": {
+		{"is_geaard", 1}, {"is_kinderveilig", 2}, {"aantal", 4}, {"nr", 10}, {"adres", 15},
+		{"aantal_fases_indien_meerfasig", 16}, {"heeft_ingebouwde_schakelaar", 19}, {"is_halfwaterdicht", 20},
+		{"is_meerfasig", 21}, {"heeft_nul_indien_meerfasig", 25}, {"in_verdeelbord", 26},
+	},
+	"Diepvriezer": legacyNrAdres,
+	"Domotica":    {{"nr", 10}, {"tekst", 15}, {"adres", 23}},
+	"Domotica gestuurde verbruiker": {
+		{"type_externe_sturing", 5}, {"nr", 10}, {"adres", 15}, {"is_draadloos", 19}, {"heeft_lokale_drukknop", 20},
+		{"is_geprogrammeerd", 21}, {"heeft_detectie", 25}, {"heeft_externe_sturing", 26},
+	},
+	"Droogkast": legacyNrAdres,
+	"Drukknop": {
+		{"aantal", 4}, {"nr", 10}, {"aantal_knoppen_per_armatuur", 13}, {"adres", 15}, {"type_knop", 16},
+		{"is_afgeschermd", 19}, {"is_halfwaterdicht", 20}, {"heeft_verklikkerlampje", 21},
+	},
+	"Elektriciteitsmeter": legacyNrAdres,
+	"Elektrische oven":    legacyNrAdres,
+	"EV lader":            legacyNrAdres,
+	"Ketel": {
+		{"aantal", 4}, {"nr", 10}, {"adres", 15}, {"keteltype", 16}, {"energiebron", 17}, {"warmtefunctie", 18},
+	},
+	"Koelkast":    legacyNrAdres,
+	"Kookfornuis": legacyNrAdres,
+	"Kring": append([]legacyKey{
+		{"type_kabel", 9}, {"naam", 10}, {"kabel_is_aanwezig", 12}, {"tekst", 15}, {"kabel_locatie", 16}, {"kabel_is_in_buis", 19},
+	}, legacyProtection...),
+	"Lichtcircuit": append([]legacyKey{{"aantal_lichtpunten", 13}}, legacySchakelaars...),
+	"Lichtpunt": {
+		{"aantal", 4}, {"nr", 10}, {"aantal_buizen_indien_TL", 13}, {"adres", 15}, {"type_lamp", 16},
+		{"type_noodverlichting", 17}, {"is_wandlamp", 19}, {"is_halfwaterdicht", 20}, {"heeft_ingebouwde_schakelaar", 21},
+	},
+	"Meerdere verbruikers":     legacyNrAdres,
+	"Microgolfoven":            legacyNrAdres,
+	"Motor":                    legacyNrAdres,
+	"Omvormer":                 legacyNrAdres,
+	"Overspanningsbeveiliging": legacyNrAdres,
+	"Schakelaars":              legacySchakelaars,
+	"Splitsing":                {{"adres", 15}},
+	"Stoomoven":                legacyNrAdres,
+	"Transformator":            {{"nr", 10}, {"voltage", 14}, {"adres", 15}},
+	"USB lader":                {{"aantal", 4}, {"nr", 10}, {"adres", 15}},
+	"Vaatwasmachine":           legacyNrAdres,
+	"Ventilator":               legacyNrAdres,
+	"Verbruiker": {
+		{"nr", 10}, {"tekst", 15}, {"horizontale_uitlijning", 17}, {"heeft_automatische_breedte", 18},
+		{"is_vet", 19}, {"is_cursief", 20}, {"breedte", 22}, {"adres", 23},
+	},
+	"Verlenging":         {{"nr", 10}, {"breedte", 22}, {"adres", 23}},
+	"Verwarmingstoestel": {{"heeft_accumulatie", 3}, {"heeft_ventilator", 6}, {"nr", 10}, {"adres", 15}},
+	"Vrije ruimte":       {{"nr", 10}, {"breedte", 22}},
+	"Vrije tekst": {
+		{"nr", 10}, {"tekst", 15}, {"vrije_tekst_type", 16}, {"horizontale_uitlijning", 17}, {"heeft_automatische_breedte", 18},
+		{"is_vet", 19}, {"is_cursief", 20}, {"breedte", 22}, {"adres", 23},
+	},
+	"Warmtepomp/airco":  {{"aantal", 4}, {"nr", 10}, {"adres", 15}, {"warmtefunctie", 18}},
+	"Wasmachine":        legacyNrAdres,
+	"Zeldzame symbolen": {{"nr", 10}, {"adres", 15}, {"symbool", 16}},
+	"Zonnepaneel":       {{"aantal", 4}, {"nr", 10}, {"adres", 15}},
+}
+
+// Upgrade converts a schema written by an older frontend (EDS001/002/003) to the current
+// EDS0040000 format. It ports upgrade_version and the legacy branch of json_to_structure in
+// src/importExport/importExport.ts. Current-version schemas are returned unchanged with upgraded=false.
+func Upgrade(text string) (out string, upgraded bool, err error) {
+	env, err := Open(text)
+	if err != nil {
+		return "", false, err
+	}
+	if env.Version == CurrentVersion {
+		return text, false, nil
+	}
+	if env.Version > CurrentVersion {
+		return "", false, invalidf("schema version %03d is newer than supported version %03d", env.Version, CurrentVersion)
+	}
+
+	var root map[string]any
+	if err := json.Unmarshal(env.JSON, &root); err != nil {
+		return "", false, invalidf("payload is not valid json: %v", err)
+	}
+	data, ok := root["data"].([]any)
+	if !ok {
+		return "", false, invalidf("payload has no data array")
+	}
+	items := make([]map[string]any, len(data))
+	for i, raw := range data {
+		it, ok := raw.(map[string]any)
+		if !ok {
+			return "", false, invalidf("data[%d] is not an object", i)
+		}
+		items[i] = it
+	}
+
+	version := env.Version
+	// Between 2023-01-11 and 2023-01-13 some files were written with props but a version 1 header.
+	if version == 1 && len(items) > 0 && items[0]["keys"] == nil && items[0]["props"] != nil {
+		version = 3
+	}
+
+	for i, it := range items {
+		if version < 3 {
+			keys, ok := it["keys"].([]any)
+			if !ok {
+				return "", false, invalidf("data[%d] has no keys (expected key-based format for version %03d)", i, version)
+			}
+			upgradeLegacyKeys(keys, version)
+			it["props"] = convertLegacyKeys(keys)
+			delete(it, "keys")
+			delete(it, "consumers")
+		} else {
+			props, ok := it["props"].(map[string]any)
+			if !ok {
+				return "", false, invalidf("data[%d] has no props", i)
+			}
+			upgradeProps(props, version)
+		}
+	}
+
+	payload, err := json.Marshal(root)
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := decodeJSON(payload); err != nil {
+		return "", false, fmt.Errorf("upgraded schema is not valid: %w", err)
+	}
+
+	format := FormatEDS
+	if props, ok := root["properties"].(map[string]any); ok && props["disableEDSCompression"] == true {
+		format = FormatTXT
+	}
+	out, err = Seal(format, CurrentVersion, payload)
+	if err != nil {
+		return "", false, err
+	}
+	return out, true, nil
+}
+
+// upgradeLegacyKeys applies the key-based part of upgrade_version (versions 1 and 2).
+func upgradeLegacyKeys(keys []any, version int) {
+	typ := legacyString(keys, 0)
+
+	// Version 1: free text without frame became 30 pixels wider (16/12/2023).
+	if version < 2 && typ == "Vrije tekst" && legacyString(keys, 16) != "verbruiker" {
+		if w, ok := (Props{"w": legacyValue(keys, 22)}).Float("w"); ok && w > 0 {
+			setLegacyKey(keys, 22, fmt.Sprint(w+30))
+		} else {
+			setLegacyKey(keys, 18, "automatisch")
+		}
+		if legacyString(keys, 16) != "zonder kader" {
+			setLegacyKey(keys, 16, "verbruiker")
+		}
+	}
+
+	// Version 2 still called Contactdozen "Stopcontact".
+	switch typ {
+	case "Stopcontact":
+		setLegacyKey(keys, 0, "Contactdoos")
+	case "Leeg":
+		setLegacyKey(keys, 0, "Aansluitpunt")
+	}
+
+	// Before version 4 board names were drawn with implicit <> around them.
+	if legacyString(keys, 0) == "Bord" && legacyString(keys, 10) != "" {
+		setLegacyKey(keys, 10, "<"+legacyString(keys, 10)+">")
+	}
+}
+
+// convertLegacyKeys builds the props object for a key-based item, like Electro_Item.convertLegacyKeys.
+func convertLegacyKeys(keys []any) map[string]any {
+	typ := legacyString(keys, 0)
+	props := map[string]any{"type": typ}
+	for _, k := range legacyKeyMap[typ] {
+		if v := legacyValue(keys, k.idx); v != nil {
+			props[k.prop] = v
+		}
+	}
+	if typ == "Kring" || typ == "Aansluiting" {
+		props["huishoudelijk"] = true
+		props["fase"] = ""
+		switch props["bescherming"] {
+		case "differentieel":
+			setIfPresent(props, "type_differentieel", legacyValue(keys, 17))
+		case "automatisch":
+			setIfPresent(props, "curve_automaat", legacyValue(keys, 17))
+		case "differentieelautomaat":
+			setIfPresent(props, "type_differentieel", legacyValue(keys, 17))
+			setIfPresent(props, "curve_automaat", legacyValue(keys, 18))
+		}
+	}
+	// Automatic numbering did not exist yet.
+	props["autoKringNaam"] = "manueel"
+	props["autonr"] = "manueel"
+	return props
+}
+
+// upgradeProps applies the props-based part of upgrade_version and json_to_structure (versions 3 and 4).
+func upgradeProps(props map[string]any, version int) {
+	p := Props(props)
+	switch p.String("type") {
+	case "Stopcontact":
+		props["type"] = "Contactdoos"
+	case "Leeg":
+		props["type"] = "Aansluitpunt"
+	}
+	if version < 4 && p.String("type") == "Bord" && p.String("naam") != "" {
+		props["naam"] = "<" + p.String("naam") + ">"
+	}
+	if p.String("type") == "Kring" && !truthy(props["autoKringNaam"]) {
+		props["autoKringNaam"] = "manueel"
+	}
+	if props["nr"] != nil && !truthy(props["autonr"]) {
+		props["autonr"] = "manueel"
+	}
+	if p.String("type") == "Batterij" && props["symbool"] == nil {
+		props["symbool"] = "blokbatterij"
+	}
+}
+
+func legacyValue(keys []any, idx int) any {
+	if idx >= len(keys) {
+		return nil
+	}
+	k, ok := keys[idx].([]any)
+	if !ok || len(k) < 3 {
+		return nil
+	}
+	return k[2]
+}
+
+func legacyString(keys []any, idx int) string {
+	return Props{"v": legacyValue(keys, idx)}.String("v")
+}
+
+func setLegacyKey(keys []any, idx int, v any) {
+	if idx >= len(keys) {
+		return
+	}
+	if k, ok := keys[idx].([]any); ok && len(k) >= 3 {
+		k[2] = v
+	}
+}
+
+func setIfPresent(props map[string]any, key string, v any) {
+	if v != nil {
+		props[key] = v
+	}
+}
+
+// truthy mirrors JavaScript truthiness for the JSON value types.
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case float64:
+		return x != 0
+	default:
+		return true
+	}
+}
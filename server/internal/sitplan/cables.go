@@ -0,0 +1,593 @@
+// Package sitplan computes cable lengths from the situation plan of a schema.
+// It is a port of src/sitplan/CableLengthCalculator.ts; results must match what the frontend shows.
+package sitplan
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"eendraadschema-share-server/internal/schema"
+)
+
+const (
+	// UnknownSpec is used for runs without a cable spec and for risers shared by several specs.
+	UnknownSpec = "Onbekend"
+
+	// Snap distances (in sitplan units) for attaching run endpoints to connection points and devices.
+	connectionSnap = 14
+	deviceSnap     = 18
+
+	defaultDeviceHeightCm     = 30
+	defaultCablePlaneOffsetCm = -10
+)
+
+// Summary is the cable length of a kring, summed over its components.
+type Summary struct {
+	MetersBySpec         map[string]float64 `json:"metersBySpec"`
+	UnknownScaleRuns     int                `json:"unknownScaleRuns"`
+	UnknownRiserCount    int                `json:"unknownRiserCount"`
+	UnknownEndpointCount int                `json:"unknownEndpointCount"`
+}
+
+// Component is a set of cable runs connected through shared devices or connection points.
+type Component struct {
+	ID string `json:"id"`
+	// Runs holds the indexes of the cableRun elements in SitPlan.Elements.
+	Runs                 []int              `json:"runs"`
+	ConnectionIDs        []string           `json:"connectionIds"`
+	Risers               []Riser            `json:"risers"`
+	MetersBySpec         map[string]float64 `json:"metersBySpec"`
+	UnknownScaleRuns     int                `json:"unknownScaleRuns"`
+	UnknownRiserCount    int                `json:"unknownRiserCount"`
+	UnknownEndpointCount int                `json:"unknownEndpointCount"`
+}
+
+// Riser is the vertical cable at a connection point that links runs on different pages.
+// Meters is nil when the elevation of one of the pages' floors is unknown.
+type Riser struct {
+	ConnectionID string   `json:"connectionId"`
+	Pages        []int    `json:"pages"`
+	CableSpec    string   `json:"cableSpec"`
+	Meters       *float64 `json:"meters"`
+}
+
+// RunDetail is the length of a single cable run. Meter values are nil when the page has no scale.
+type RunDetail struct {
+	Run                  int      `json:"run"`
+	Page                 int      `json:"page"`
+	CableSpec            string   `json:"cableSpec"`
+	MetersHorizontal     *float64 `json:"metersHorizontal"`
+	MetersEndpointDrops  *float64 `json:"metersEndpointDrops"`
+	MetersTotal          *float64 `json:"metersTotal"`
+	ConnectionIDs        []string `json:"connectionIds"`
+	UnknownScale         bool     `json:"unknownScale"`
+	UnknownEndpointCount int      `json:"unknownEndpointCount"`
+}
+
+type point struct{ x, y float64 }
+
+type attachmentKind int
+
+const (
+	attachFree attachmentKind = iota
+	attachConnection
+	attachDevice
+)
+
+type attachment struct {
+	kind         attachmentKind
+	key          string
+	connectionID string
+	page         int
+}
+
+type edge struct {
+	run  int
+	el   *schema.SitPlanElement
+	spec string
+	a, b attachment
+}
+
+// calculator holds the per-plan lookups shared by all kringen.
+type calculator struct {
+	doc               *schema.Document
+	sp                *schema.SitPlan
+	floors            []schema.Floor
+	pageFloorIDs      []*string
+	deviceHeightCm    float64
+	connectionsByPage map[int][]*schema.SitPlanElement
+	devicesByPage     map[int][]int
+}
+
+func newCalculator(doc *schema.Document) *calculator {
+	sp := doc.SitPlan
+	c := &calculator{
+		doc:               doc,
+		sp:                sp,
+		deviceHeightCm:    defaultDeviceHeightCm,
+		connectionsByPage: map[int][]*schema.SitPlanElement{},
+		devicesByPage:     map[int][]int{},
+	}
+	if sp == nil {
+		return c
+	}
+	if v, ok := schema.Props(sp.Defaults).Float("defaultDeviceHeightCm"); ok && v >= 0 {
+		c.deviceHeightCm = v
+	}
+
+	// Like ensureFloorsInitialized/ensurePageFloorIdsLength: plans without floors get a single
+	// ground floor, and pages without an entry are put on the first floor.
+	c.floors = sp.Floors
+	if len(c.floors) == 0 {
+		zero, offset := schema.Num(0), schema.Num(defaultCablePlaneOffsetCm)
+		c.floors = []schema.Floor{{ID: "", Name: "Gelijkvloers", ElevationCm: &zero, CablePlaneOffsetCm: &offset}}
+	}
+	c.pageFloorIDs = append([]*string(nil), sp.PageFloorIDs...)
+	first := c.floors[0].ID
+	for len(c.pageFloorIDs) < sp.NumPages {
+		c.pageFloorIDs = append(c.pageFloorIDs, &first)
+	}
+
+	for i := range sp.Elements {
+		el := &sp.Elements[i]
+		if el.EffectiveKind() == "connectionPoint" && el.ConnectionPoint != nil {
+			c.connectionsByPage[el.Page] = append(c.connectionsByPage[el.Page], el)
+		}
+		if c.isDevice(el) {
+			c.devicesByPage[el.Page] = append(c.devicesByPage[el.Page], i)
+		}
+	}
+	return c
+}
+
+// isDevice mirrors getElectroItemId() != null: the element depicts an item that exists in the schema.
+func (c *calculator) isDevice(el *schema.SitPlanElement) bool {
+	if el.ElectroItemID == nil || *el.ElectroItemID == 0 {
+		return false
+	}
+	_, ok := c.doc.ItemByID(*el.ElectroItemID)
+	return ok
+}
+
+func (c *calculator) metersPerUnit(page int) (float64, bool) {
+	if page < 1 || page > len(c.sp.PageMetersPerUnit) || page > c.sp.NumPages {
+		return 0, false
+	}
+	v := c.sp.PageMetersPerUnit[page-1]
+	if v == nil || *v <= 0 || math.IsInf(float64(*v), 0) || math.IsNaN(float64(*v)) {
+		return 0, false
+	}
+	return float64(*v), true
+}
+
+func (c *calculator) floorForPage(page int) *schema.Floor {
+	if page < 1 || page > len(c.pageFloorIDs) || page > c.sp.NumPages {
+		return nil
+	}
+	id := c.pageFloorIDs[page-1]
+	if id == nil {
+		return nil
+	}
+	for i := range c.floors {
+		if c.floors[i].ID == *id {
+			return &c.floors[i]
+		}
+	}
+	return nil
+}
+
+func (c *calculator) cablePlaneOffsetCm(page int) float64 {
+	if f := c.floorForPage(page); f != nil && f.CablePlaneOffsetCm != nil {
+		return float64(*f.CablePlaneOffsetCm)
+	}
+	return defaultCablePlaneOffsetCm
+}
+
+func (c *calculator) cablePlaneElevationCm(page int) (float64, bool) {
+	f := c.floorForPage(page)
+	if f == nil || f.ElevationCm == nil {
+		return 0, false
+	}
+	return float64(*f.ElevationCm) + c.cablePlaneOffsetCm(page), true
+}
+
+// runPoints returns the points of a run in page coordinates (they are stored relative to its bounding box).
+func runPoints(el *schema.SitPlanElement) []point {
+	if el.CableRun == nil || len(el.CableRun.Points) < 2 {
+		return nil
+	}
+	minx := float64(el.PosX) - float64(el.SizeX)/2
+	miny := float64(el.PosY) - float64(el.SizeY)/2
+	out := make([]point, len(el.CableRun.Points))
+	for i, p := range el.CableRun.Points {
+		out[i] = point{float64(p.X) + minx, float64(p.Y) + miny}
+	}
+	return out
+}
+
+func lengthUnits(el *schema.SitPlanElement) float64 {
+	pts := runPoints(el)
+	sum := 0.0
+	for i := 0; i+1 < len(pts); i++ {
+		sum += math.Hypot(pts[i+1].x-pts[i].x, pts[i+1].y-pts[i].y)
+	}
+	return sum
+}
+
+func endpoints(el *schema.SitPlanElement) (a, b *point) {
+	pts := runPoints(el)
+	if len(pts) < 2 {
+		return nil, nil
+	}
+	return &pts[0], &pts[len(pts)-1]
+}
+
+func (c *calculator) connectionNear(page int, p *point) *schema.SitPlanElement {
+	if p == nil {
+		return nil
+	}
+	var best *schema.SitPlanElement
+	bestD2 := math.Inf(1)
+	for _, cp := range c.connectionsByPage[page] {
+		dx, dy := float64(cp.PosX)-p.x, float64(cp.PosY)-p.y
+		if d2 := dx*dx + dy*dy; d2 <= connectionSnap*connectionSnap && d2 < bestD2 {
+			bestD2, best = d2, cp
+		}
+	}
+	return best
+}
+
+func (c *calculator) deviceNear(page int, p *point) (int, bool) {
+	if p == nil {
+		return 0, false
+	}
+	best, found := 0, false
+	bestD2 := math.Inf(1)
+	for _, i := range c.devicesByPage[page] {
+		el := &c.sp.Elements[i]
+		dx, dy := float64(el.PosX)-p.x, float64(el.PosY)-p.y
+		if d2 := dx*dx + dy*dy; d2 <= deviceSnap*deviceSnap && d2 < bestD2 {
+			bestD2, best, found = d2, i, true
+		}
+	}
+	return best, found
+}
+
+// dropMeters is the vertical cable from the cable plane up (or down) to the device at p.
+func (c *calculator) dropMeters(page int, p *point) (float64, bool) {
+	i, ok := c.deviceNear(page, p)
+	if !ok {
+		return 0, false
+	}
+	h := c.deviceHeightCm
+	if el := &c.sp.Elements[i]; el.HeightCm != nil {
+		h = float64(*el.HeightCm)
+	}
+	return math.Abs(h-c.cablePlaneOffsetCm(page)) / 100, true
+}
+
+func connectionID(cp *schema.SitPlanElement) string {
+	id := strings.TrimSpace(cp.ConnectionPoint.ConnectionID)
+	if id == "" {
+		return "CP"
+	}
+	return id
+}
+
+func (c *calculator) attach(run int, page int, p *point, side string) attachment {
+	if cp := c.connectionNear(page, p); cp != nil {
+		cid := connectionID(cp)
+		return attachment{kind: attachConnection, key: "cid:" + cid, connectionID: cid, page: page}
+	}
+	if i, ok := c.deviceNear(page, p); ok {
+		return attachment{kind: attachDevice, key: fmt.Sprintf("dev:%d", i), page: page}
+	}
+	return attachment{kind: attachFree, key: fmt.Sprintf("free:%d:%s", run, side), page: page}
+}
+
+// runsOfKring returns the indexes of the cableRun elements assigned to kring.
+func (c *calculator) runsOfKring(kring string) []int {
+	if c.sp == nil {
+		return nil
+	}
+	var out []int
+	for i := range c.sp.Elements {
+		el := &c.sp.Elements[i]
+		if el.EffectiveKind() == "cableRun" && el.CableRun != nil && el.CableRun.Kring != nil && *el.CableRun.Kring == kring {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func normalizeSpec(el *schema.SitPlanElement) string {
+	if el.CableRun.CableSpec != nil {
+		if s := strings.TrimSpace(*el.CableRun.CableSpec); s != "" {
+			return s
+		}
+	}
+	return UnknownSpec
+}
+
+// Kringen returns the distinct, trimmed kring names that cable runs are assigned to.
+func Kringen(doc *schema.Document) []string {
+	if doc.SitPlan == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, el := range doc.SitPlan.Elements {
+		if el.EffectiveKind() != "cableRun" || el.CableRun == nil || el.CableRun.Kring == nil {
+			continue
+		}
+		k := strings.TrimSpace(*el.CableRun.Kring)
+		if k != "" && !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ComponentsForKring groups the runs of a kring into connected components and computes their lengths:
+// horizontal length, drops to the devices at run endpoints, and risers between floors at shared
+// connection points.
+func ComponentsForKring(doc *schema.Document, kring string) []Component {
+	c := newCalculator(doc)
+	runs := c.runsOfKring(kring)
+	if len(runs) == 0 {
+		return nil
+	}
+
+	uf := unionFind{}
+	edges := make([]edge, 0, len(runs))
+	for _, i := range runs {
+		el := &c.sp.Elements[i]
+		pa, pb := endpoints(el)
+		e := edge{run: i, el: el, spec: normalizeSpec(el), a: c.attach(i, el.Page, pa, "a"), b: c.attach(i, el.Page, pb, "b")}
+		uf.union(e.a.key, e.b.key)
+		edges = append(edges, e)
+	}
+
+	var roots []string
+	byRoot := map[string][]edge{}
+	for _, e := range edges {
+		root := uf.find(e.a.key)
+		if _, ok := byRoot[root]; !ok {
+			roots = append(roots, root)
+		}
+		byRoot[root] = append(byRoot[root], e)
+	}
+
+	out := make([]Component, 0, len(roots))
+	for idx, root := range roots {
+		compEdges := byRoot[root]
+		comp := Component{MetersBySpec: map[string]float64{}, ConnectionIDs: []string{}, Risers: []Riser{}}
+		if len(root) > 8 {
+			comp.ID = fmt.Sprintf("comp_%d_%s", idx+1, root[:8])
+		} else {
+			comp.ID = fmt.Sprintf("comp_%d_%s", idx+1, root)
+		}
+
+		// Which pages and specs touch which connection id inside this component.
+		cidPages := map[string]map[int]bool{}
+		cidSpecs := map[string]map[string]bool{}
+		for _, e := range compEdges {
+			comp.Runs = append(comp.Runs, e.run)
+			for _, att := range []attachment{e.a, e.b} {
+				if att.kind != attachConnection {
+					continue
+				}
+				if cidPages[att.connectionID] == nil {
+					cidPages[att.connectionID] = map[int]bool{}
+					cidSpecs[att.connectionID] = map[string]bool{}
+				}
+				cidPages[att.connectionID][att.page] = true
+				cidSpecs[att.connectionID][e.spec] = true
+			}
+		}
+
+		// Horizontal length plus endpoint drops.
+		for _, e := range compEdges {
+			mpu, ok := c.metersPerUnit(e.el.Page)
+			if !ok {
+				comp.UnknownScaleRuns++
+				continue
+			}
+			addMeters(comp.MetersBySpec, e.spec, lengthUnits(e.el)*mpu)
+			pa, pb := endpoints(e.el)
+			for _, ep := range []struct {
+				p   *point
+				att attachment
+			}{{pa, e.a}, {pb, e.b}} {
+				if ep.att.kind == attachConnection {
+					continue
+				}
+				m, ok := c.dropMeters(e.el.Page, ep.p)
+				if !ok {
+					comp.UnknownEndpointCount++
+					continue
+				}
+				addMeters(comp.MetersBySpec, e.spec, m)
+			}
+		}
+
+		// Risers per connection id: from the lowest to the highest cable plane it is used on.
+		for cid := range cidPages {
+			comp.ConnectionIDs = append(comp.ConnectionIDs, cid)
+		}
+		sort.Strings(comp.ConnectionIDs)
+		for _, cid := range comp.ConnectionIDs {
+			riser := Riser{ConnectionID: cid, CableSpec: UnknownSpec}
+			if len(cidSpecs[cid]) == 1 {
+				for s := range cidSpecs[cid] {
+					riser.CableSpec = s
+				}
+			}
+			lo, hi := math.Inf(1), math.Inf(-1)
+			known := 0
+			for p := range cidPages[cid] {
+				riser.Pages = append(riser.Pages, p)
+				if elev, ok := c.cablePlaneElevationCm(p); ok {
+					lo, hi = math.Min(lo, elev), math.Max(hi, elev)
+					known++
+				}
+			}
+			sort.Ints(riser.Pages)
+			if known < 2 || known != len(riser.Pages) {
+				comp.UnknownRiserCount++
+			} else {
+				m := math.Max(0, (hi-lo)/100)
+				addMeters(comp.MetersBySpec, riser.CableSpec, m)
+				riser.Meters = ptr(round1(m))
+			}
+			comp.Risers = append(comp.Risers, riser)
+		}
+		normalizeMeters(comp.MetersBySpec)
+		out = append(out, comp)
+	}
+
+	// Largest components first.
+	sort.SliceStable(out, func(i, j int) bool {
+		ti, tj := totalMeters(out[i].MetersBySpec), totalMeters(out[j].MetersBySpec)
+		if ti != tj {
+			return ti > tj
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// RunDetailsForKring returns the length of each run of a kring, longest first.
+func RunDetailsForKring(doc *schema.Document, kring string) []RunDetail {
+	c := newCalculator(doc)
+	runs := c.runsOfKring(kring)
+	out := make([]RunDetail, 0, len(runs))
+	for _, i := range runs {
+		el := &c.sp.Elements[i]
+		pa, pb := endpoints(el)
+		d := RunDetail{Run: i, Page: el.Page, CableSpec: normalizeSpec(el), ConnectionIDs: []string{}}
+
+		touched := map[string]bool{}
+		for _, p := range []*point{pa, pb} {
+			if cp := c.connectionNear(el.Page, p); cp != nil {
+				touched[connectionID(cp)] = true
+			}
+		}
+		for cid := range touched {
+			d.ConnectionIDs = append(d.ConnectionIDs, cid)
+		}
+		sort.Strings(d.ConnectionIDs)
+
+		mpu, ok := c.metersPerUnit(el.Page)
+		d.UnknownScale = !ok
+		if ok {
+			horizontal := lengthUnits(el) * mpu
+			drops := 0.0
+			for _, p := range []*point{pa, pb} {
+				if c.connectionNear(el.Page, p) != nil {
+					continue
+				}
+				m, ok := c.dropMeters(el.Page, p)
+				if !ok {
+					d.UnknownEndpointCount++
+					continue
+				}
+				drops += m
+			}
+			d.MetersHorizontal = ptr(round1(horizontal))
+			d.MetersEndpointDrops = ptr(round1(drops))
+			d.MetersTotal = ptr(round1(horizontal + drops))
+		}
+		out = append(out, d)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		ti, tj := -1.0, -1.0
+		if out[i].MetersTotal != nil {
+			ti = *out[i].MetersTotal
+		}
+		if out[j].MetersTotal != nil {
+			tj = *out[j].MetersTotal
+		}
+		if ti != tj {
+			return ti > tj
+		}
+		if out[i].Page != out[j].Page {
+			return out[i].Page < out[j].Page
+		}
+		return out[i].Run < out[j].Run
+	})
+	return out
+}
+
+// SummaryForKring sums the components of a kring.
+func SummaryForKring(doc *schema.Document, kring string) Summary {
+	s := Summary{MetersBySpec: map[string]float64{}}
+	for _, comp := range ComponentsForKring(doc, kring) {
+		s.UnknownScaleRuns += comp.UnknownScaleRuns
+		s.UnknownRiserCount += comp.UnknownRiserCount
+		s.UnknownEndpointCount += comp.UnknownEndpointCount
+		for spec, m := range comp.MetersBySpec {
+			addMeters(s.MetersBySpec, spec, m)
+		}
+	}
+	normalizeMeters(s.MetersBySpec)
+	return s
+}
+
+type unionFind map[string]string
+
+func (u unionFind) find(x string) string {
+	p, ok := u[x]
+	if !ok {
+		u[x] = x
+		return x
+	}
+	if p == x {
+		return x
+	}
+	root := u.find(p)
+	u[x] = root
+	return root
+}
+
+func (u unionFind) union(a, b string) {
+	ra, rb := u.find(a), u.find(b)
+	if ra != rb {
+		u[ra] = rb
+	}
+}
+
+func addMeters(m map[string]float64, spec string, meters float64) {
+	if math.IsNaN(meters) || math.IsInf(meters, 0) || meters <= 0 {
+		return
+	}
+	m[spec] += meters
+}
+
+func normalizeMeters(m map[string]float64) {
+	for k, v := range m {
+		if v <= 0 {
+			delete(m, k)
+		} else {
+			m[k] = round1(v)
+		}
+	}
+}
+
+func totalMeters(m map[string]float64) float64 {
+	sum := 0.0
+	for _, v := range m {
+		sum += v
+	}
+	return sum
+}
+
+// round1 rounds to one decimal like Math.round(v * 10) / 10.
+func round1(v float64) float64 { return math.Floor(v*10+0.5) / 10 }
+
+func ptr(v float64) *float64 { return &v }
@@ -0,0 +1,100 @@
+package sitplan
+
+import (
+	"sort"
+
+	"eendraadschema-share-server/internal/schema"
+)
+
+// KringLengths is the cable length of one kring with the runs and components it is made of.
+type KringLengths struct {
+	Kring string `json:"kring"`
+	Label string `json:"label"`
+	Summary
+	Components []Component `json:"components"`
+	Runs       []RunDetail `json:"runs"`
+}
+
+// FloorLengths sums the runs drawn on the pages of one floor. Risers connect floors and are only
+// counted per kring, so the floors do not add up to the total.
+type FloorLengths struct {
+	FloorID          string             `json:"floorId"`
+	Name             string             `json:"name"`
+	ElevationCm      *float64           `json:"elevationCm"`
+	Pages            []int              `json:"pages"`
+	MetersBySpec     map[string]float64 `json:"metersBySpec"`
+	UnknownScaleRuns int                `json:"unknownScaleRuns"`
+}
+
+// Report holds the cable lengths of a whole plan.
+type Report struct {
+	Summary
+	Kringen []KringLengths `json:"kringen"`
+	Floors  []FloorLengths `json:"floors"`
+}
+
+// Compute returns the cable lengths of every kring that has runs, and of every floor.
+func Compute(doc *schema.Document) *Report {
+	rep := &Report{Summary: Summary{MetersBySpec: map[string]float64{}}, Kringen: []KringLengths{}, Floors: []FloorLengths{}}
+	if doc.SitPlan == nil {
+		return rep
+	}
+	c := newCalculator(doc)
+	labels := doc.KringLabels()
+
+	// Pages without a (known) floor are grouped under an empty floor id.
+	floors := map[string]*FloorLengths{}
+	var floorOrder []string
+	floorFor := func(page int) *FloorLengths {
+		id, name := "", ""
+		var elev *float64
+		if f := c.floorForPage(page); f != nil {
+			id, name = f.ID, f.Name
+			if f.ElevationCm != nil {
+				elev = ptr(float64(*f.ElevationCm))
+			}
+		}
+		fl, ok := floors[id]
+		if !ok {
+			fl = &FloorLengths{FloorID: id, Name: name, ElevationCm: elev, Pages: []int{}, MetersBySpec: map[string]float64{}}
+			floors[id] = fl
+			floorOrder = append(floorOrder, id)
+		}
+		return fl
+	}
+	for page := 1; page <= doc.SitPlan.NumPages; page++ {
+		fl := floorFor(page)
+		fl.Pages = append(fl.Pages, page)
+	}
+
+	for _, k := range Kringen(doc) {
+		kl := KringLengths{Kring: k, Label: k, Summary: SummaryForKring(doc, k), Components: ComponentsForKring(doc, k), Runs: RunDetailsForKring(doc, k)}
+		if l, ok := labels[k]; ok {
+			kl.Label = l
+		}
+		rep.UnknownScaleRuns += kl.UnknownScaleRuns
+		rep.UnknownRiserCount += kl.UnknownRiserCount
+		rep.UnknownEndpointCount += kl.UnknownEndpointCount
+		for spec, m := range kl.MetersBySpec {
+			addMeters(rep.MetersBySpec, spec, m)
+		}
+		for _, run := range kl.Runs {
+			fl := floorFor(run.Page)
+			if run.MetersTotal == nil {
+				fl.UnknownScaleRuns++
+				continue
+			}
+			addMeters(fl.MetersBySpec, run.CableSpec, *run.MetersTotal)
+		}
+		rep.Kringen = append(rep.Kringen, kl)
+	}
+	normalizeMeters(rep.MetersBySpec)
+
+	for _, id := range floorOrder {
+		fl := floors[id]
+		normalizeMeters(fl.MetersBySpec)
+		sort.Ints(fl.Pages)
+		rep.Floors = append(rep.Floors, *fl)
+	}
+	return rep
+}
@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"eendraadschema-share-server/internal/delta"
+	"eendraadschema-share-server/internal/schema"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SchemaBlobModel holds each distinct schema body once, keyed by schema.Hash. Shares and versions
+// refer to it through their schema_hash column. TouchedAt is bumped whenever a new reference is
+// written, so the collector keeps blobs that a concurrent write is about to use.
+//
+// Older versions are stored as deltas: Body is empty, and Delta turns the normalised JSON of the
+// BaseHash blob into this one's, which is sealed again as Format and Version. Size is the number of
+// bytes actually stored.
+type SchemaBlobModel struct {
+	Hash      string `gorm:"column:hash;primaryKey"`
+	Body      string `gorm:"column:body;not null"`
+	Size      int64  `gorm:"column:size;not null"`
+	BaseHash  string `gorm:"column:base_hash;not null;default:'';index"`
+	Delta     []byte `gorm:"column:delta"`
+	Format    string `gorm:"column:format;not null;default:''"`
+	Version   int    `gorm:"column:version;not null;default:0"`
+	CreatedAt int64  `gorm:"column:created_at;not null"`
+	TouchedAt int64  `gorm:"column:touched_at;not null;index"`
+}
+
+func (SchemaBlobModel) TableName() string { return "schema_blobs" }
+
+const (
+	// blobGracePeriod is how long an unreferenced blob is kept after it was last referenced.
+	blobGracePeriod = time.Hour
+	// deltaSnapshotEvery keeps one full version after this many deltas, which bounds the work
+	// of reconstructing an old version.
+	deltaSnapshotEvery = 20
+	// maxDeltaChain guards against corrupt chains; normal chains are shorter than deltaSnapshotEvery.
+	maxDeltaChain = 500
+)
+
+// putBlob stores body as a full blob and returns its hash. An existing blob with the same hash is
+// kept, but stored in full again if it had become a delta: the newest schema is always whole, so a
+// delta's base can never (transitively) be a delta of itself.
+func putBlob(tx *gorm.DB, body string, now time.Time) (string, error) {
+	m := SchemaBlobModel{Hash: schema.Hash(body), Body: body, Size: int64(len(body)), CreatedAt: now.Unix(), TouchedAt: now.Unix()}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"touched_at", "body", "size", "base_hash", "delta", "format", "version"}),
+	}).Create(&m).Error
+	return m.Hash, err
+}
+
+func loadBlob(tx *gorm.DB, hash string) (SchemaBlobModel, error) {
+	var m SchemaBlobModel
+	if err := tx.Take(&m, "hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return SchemaBlobModel{}, ErrNotFound
+		}
+		return SchemaBlobModel{}, err
+	}
+	return m, nil
+}
+
+// blobBody returns the schema stored under hash, rebuilding it when it is a delta. Rows written
+// before blobs existed have no hash and keep their schema inline.
+func blobBody(tx *gorm.DB, hash string, inline string) (string, error) {
+	if hash == "" {
+		return inline, nil
+	}
+	m, err := loadBlob(tx, hash)
+	if err != nil {
+		return "", err
+	}
+	if m.BaseHash == "" {
+		return m.Body, nil
+	}
+	payload, err := blobJSON(tx, m, 0)
+	if err != nil {
+		return "", err
+	}
+	return schema.Seal(schema.Format(m.Format), m.Version, payload)
+}
+
+// blobJSON returns the normalised JSON payload of a blob, following its delta chain.
+func blobJSON(tx *gorm.DB, m SchemaBlobModel, depth int) ([]byte, error) {
+	if m.BaseHash == "" {
+		env, err := schema.Normalize(m.Body)
+		if err != nil {
+			return nil, err
+		}
+		return env.JSON, nil
+	}
+	if depth >= maxDeltaChain {
+		return nil, fmt.Errorf("schema blob %s: delta chain is too long", m.Hash)
+	}
+	base, err := loadBlob(tx, m.BaseHash)
+	if err != nil {
+		return nil, fmt.Errorf("schema blob %s: base %s: %w", m.Hash, m.BaseHash, err)
+	}
+	basePayload, err := blobJSON(tx, base, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return delta.Apply(basePayload, m.Delta)
+}
+
+// deltifyBlob stores blob old, the version before newer in a share's history, as a delta against
+// newer. It leaves old whole when it is already a delta, is the current schema of some share,
+// would end a run of deltaSnapshotEvery deltas (it then serves as a snapshot), or when the delta
+// does not save at least half.
+func deltifyBlob(tx *gorm.DB, shareID string, old string, newer string) error {
+	m, err := loadBlob(tx, old)
+	if err != nil || m.BaseHash != "" {
+		return nil
+	}
+	var current int64
+	if err := tx.Model(&ShareModel{}).Where("schema_hash = ?", old).Count(&current).Error; err != nil || current > 0 {
+		return err
+	}
+
+	var older []string
+	if err := tx.Model(&ShareVersionModel{}).
+		Where("share_id = ? AND schema_hash NOT IN ?", shareID, []string{old, newer}).
+		Order("created_at DESC").
+		Limit(deltaSnapshotEvery-1).
+		Pluck("schema_hash", &older).Error; err != nil {
+		return err
+	}
+	if len(older) == deltaSnapshotEvery-1 {
+		var bases []SchemaBlobModel
+		if err := tx.Select("hash", "base_hash").Where("hash IN ?", older).Find(&bases).Error; err != nil {
+			return err
+		}
+		isDelta := map[string]bool{}
+		for _, b := range bases {
+			isDelta[b.Hash] = b.BaseHash != ""
+		}
+		run := 0
+		for run < len(older) && isDelta[older[run]] {
+			run++
+		}
+		if run == len(older) {
+			return nil
+		}
+	}
+
+	target, err := schema.Normalize(m.Body)
+	if err != nil {
+		return nil
+	}
+	n, err := loadBlob(tx, newer)
+	if err != nil || n.BaseHash != "" {
+		return err
+	}
+	base, err := schema.Normalize(n.Body)
+	if err != nil {
+		return nil
+	}
+	d := delta.Encode(base.JSON, target.JSON)
+	if 2*len(d) > len(m.Body) {
+		return nil
+	}
+	return tx.Model(&SchemaBlobModel{}).Where("hash = ?", old).Updates(map[string]any{
+		"body":      "",
+		"size":      len(d),
+		"base_hash": newer,
+		"delta":     d,
+		"format":    string(target.Format),
+		"version":   target.Version,
+	}).Error
+}
+
+// deleteUnreferencedBlobs deletes blobs no share or version refers to, that no delta is based on
+// and that were not touched within the grace period. scope narrows the candidates. Deleting a
+// delta can free its base, so it repeats until nothing more is deleted.
+func deleteUnreferencedBlobs(tx *gorm.DB, scope func(*gorm.DB) *gorm.DB, now time.Time) (int64, error) {
+	var total int64
+	for range maxDeltaChain {
+		res := scope(tx.Session(&gorm.Session{NewDB: true})).
+			Where("touched_at < ?", now.Add(-blobGracePeriod).Unix()).
+			Where("NOT EXISTS (SELECT 1 FROM shares WHERE shares.schema_hash = schema_blobs.hash)").
+			Where("NOT EXISTS (SELECT 1 FROM share_versions WHERE share_versions.schema_hash = schema_blobs.hash)").
+			Where("NOT EXISTS (SELECT 1 FROM schema_blobs AS d WHERE d.base_hash = schema_blobs.hash)").
+			Delete(&SchemaBlobModel{})
+		if res.Error != nil {
+			return total, res.Error
+		}
+		if res.RowsAffected == 0 {
+			break
+		}
+		total += res.RowsAffected
+	}
+	return total, nil
+}
+
+// collectBlobs deletes those of the given blobs, and the bases of deltas among them, that are no
+// longer referenced.
+func collectBlobs(tx *gorm.DB, hashes []string, now time.Time) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	var bases []string
+	if err := tx.Model(&SchemaBlobModel{}).Where("hash IN ? AND base_hash <> ''", hashes).Pluck("base_hash", &bases).Error; err != nil {
+		return err
+	}
+	_, err := deleteUnreferencedBlobs(tx, func(q *gorm.DB) *gorm.DB {
+		return q.Where("hash IN ?", append(bases, hashes...))
+	}, now)
+	return err
+}
+
+// CollectSchemaBlobs deletes every unreferenced blob and returns how many were removed. Blobs are
+// also collected when versions are pruned and shares deleted; this sweeps up anything left behind.
+func (s *Store) CollectSchemaBlobs(ctx context.Context, now time.Time) (int64, error) {
+	return deleteUnreferencedBlobs(s.db.WithContext(ctx), func(q *gorm.DB) *gorm.DB { return q }, now)
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateSchemaBlobs moves schemas stored inline in shares and share_versions into blobs.
+func (s *Store) migrateSchemaBlobs(ctx context.Context) error {
+	move := func(model any) error {
+		for {
+			type row struct {
+				ID     string
+				Schema string
+			}
+			var rows []row
+			if err := s.db.WithContext(ctx).Model(model).
+				Select("id", "schema").
+				Where("schema_hash = '' AND schema <> ''").
+				Order("id").
+				Limit(100).
+				Scan(&rows).Error; err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			now := time.Now()
+			err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				for _, r := range rows {
+					hash, err := putBlob(tx, r.Schema, now)
+					if err != nil {
+						return err
+					}
+					if err := tx.Model(model).Where("id = ?", r.ID).
+						Updates(map[string]any{"schema_hash": hash, "schema": ""}).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := move(&ShareModel{}); err != nil {
+		return err
+	}
+	return move(&ShareVersionModel{})
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ShareCommentModel is a remark on a share. A thread starts with a comment anchored to an item
+// of the schema as it was in VersionID; replies have the ThreadID of that first comment (which has
+// its own ID there) and record the version they were written against. Only the first comment of a
+// thread carries its resolved state.
+type ShareCommentModel struct {
+	ID            string `gorm:"column:id;primaryKey"`
+	ShareID       string `gorm:"column:share_id;not null;index"`
+	ThreadID      string `gorm:"column:thread_id;not null;index"`
+	ItemID        int    `gorm:"column:item_id;not null"`
+	VersionID     string `gorm:"column:version_id;not null;default:''"`
+	AuthorSub     string `gorm:"column:author_sub;not null;default:''"`
+	AuthorName    string `gorm:"column:author_name;not null;default:''"`
+	Body          string `gorm:"column:body;not null"`
+	CreatedAt     int64  `gorm:"column:created_at;not null;index"`
+	ResolvedAt    int64  `gorm:"column:resolved_at;not null;default:0"`
+	ResolvedBySub string `gorm:"column:resolved_by_sub;not null;default:''"`
+}
+
+func (ShareCommentModel) TableName() string { return "share_comments" }
+
+type ShareComment struct {
+	ID            string
+	ThreadID      string
+	ItemID        int
+	VersionID     string
+	AuthorSub     string
+	AuthorName    string
+	Body          string
+	CreatedAt     time.Time
+	ResolvedAt    *time.Time
+	ResolvedBySub string
+}
+
+// IsThread reports whether the comment starts a thread.
+func (c ShareComment) IsThread() bool { return c.ID == c.ThreadID }
+
+// AddShareComment stores a comment on a share. An empty ThreadID starts a new thread; otherwise
+// the comment is a reply and takes the thread's item, and ErrNotFound is returned when the share
+// has no such thread.
+func (s *Store) AddShareComment(ctx context.Context, shareID string, c ShareComment, now time.Time) (ShareComment, error) {
+	shareID = strings.TrimSpace(shareID)
+	if shareID == "" || strings.TrimSpace(c.ID) == "" {
+		return ShareComment{}, fmt.Errorf("shareID and id are required")
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if c.ThreadID == "" {
+			c.ThreadID = c.ID
+		} else {
+			var root ShareCommentModel
+			if err := tx.Take(&root, "share_id = ? AND id = ? AND thread_id = id", shareID, c.ThreadID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrNotFound
+				}
+				return err
+			}
+			c.ItemID = root.ItemID
+		}
+		c.CreatedAt = time.Unix(now.Unix(), 0)
+		return tx.Create(&ShareCommentModel{
+			ID:         c.ID,
+			ShareID:    shareID,
+			ThreadID:   c.ThreadID,
+			ItemID:     c.ItemID,
+			VersionID:  strings.TrimSpace(c.VersionID),
+			AuthorSub:  strings.TrimSpace(c.AuthorSub),
+			AuthorName: strings.TrimSpace(c.AuthorName),
+			Body:       c.Body,
+			CreatedAt:  now.Unix(),
+		}).Error
+	})
+	if err != nil {
+		return ShareComment{}, err
+	}
+	return c, nil
+}
+
+// ListShareComments returns the comments of a share, or of one thread when threadID is set,
+// oldest first.
+func (s *Store) ListShareComments(ctx context.Context, shareID string, threadID string) ([]ShareComment, error) {
+	q := s.db.WithContext(ctx).Where("share_id = ?", strings.TrimSpace(shareID))
+	if threadID = strings.TrimSpace(threadID); threadID != "" {
+		q = q.Where("thread_id = ?", threadID)
+	}
+	var rows []ShareCommentModel
+	if err := q.Order("created_at, id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]ShareComment, 0, len(rows))
+	for _, r := range rows {
+		c := ShareComment{
+			ID:            r.ID,
+			ThreadID:      r.ThreadID,
+			ItemID:        r.ItemID,
+			VersionID:     r.VersionID,
+			AuthorSub:     r.AuthorSub,
+			AuthorName:    r.AuthorName,
+			Body:          r.Body,
+			CreatedAt:     time.Unix(r.CreatedAt, 0),
+			ResolvedBySub: r.ResolvedBySub,
+		}
+		if r.ResolvedAt > 0 {
+			t := time.Unix(r.ResolvedAt, 0)
+			c.ResolvedAt = &t
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// SetShareCommentThreadResolved marks a thread resolved by resolvedBySub, or open again. It
+// returns ErrNotFound when the share has no such thread.
+func (s *Store) SetShareCommentThreadResolved(ctx context.Context, shareID string, threadID string, resolved bool, resolvedBySub string, now time.Time) error {
+	updates := map[string]any{"resolved_at": int64(0), "resolved_by_sub": ""}
+	if resolved {
+		updates = map[string]any{"resolved_at": now.Unix(), "resolved_by_sub": strings.TrimSpace(resolvedBySub)}
+	}
+	res := s.db.WithContext(ctx).Model(&ShareCommentModel{}).
+		Where("share_id = ? AND id = ? AND thread_id = id", strings.TrimSpace(shareID), strings.TrimSpace(threadID)).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
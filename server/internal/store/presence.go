@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// SharePresenceModel records that a client recently had a share open. ClientID is the user's
+// subject with OIDC (UserSub is then set too), otherwise an id chosen by the client, which also
+// sends the Name to show. Presence lives in the database so that every replica sees it.
+type SharePresenceModel struct {
+	ShareID  string `gorm:"column:share_id;primaryKey"`
+	ClientID string `gorm:"column:client_id;primaryKey"`
+	UserSub  string `gorm:"column:user_sub;not null;default:''"`
+	Name     string `gorm:"column:name;not null;default:''"`
+	State    string `gorm:"column:state;not null;default:''"`
+	SeenAt   int64  `gorm:"column:seen_at;not null;index"`
+}
+
+func (SharePresenceModel) TableName() string { return "share_presence" }
+
+const (
+	PresenceViewing = "viewing"
+	PresenceEditing = "editing"
+)
+
+type SharePresence struct {
+	ClientID string
+	UserSub  string
+	Name     string
+	State    string
+	SeenAt   time.Time
+}
+
+// TouchSharePresence records a presence heartbeat of a client on a share.
+func (s *Store) TouchSharePresence(ctx context.Context, shareID string, p SharePresence, now time.Time) error {
+	shareID = strings.TrimSpace(shareID)
+	clientID := strings.TrimSpace(p.ClientID)
+	if shareID == "" || clientID == "" {
+		return fmt.Errorf("shareID and clientID are required")
+	}
+	m := SharePresenceModel{
+		ShareID:  shareID,
+		ClientID: clientID,
+		UserSub:  strings.TrimSpace(p.UserSub),
+		Name:     strings.TrimSpace(p.Name),
+		State:    p.State,
+		SeenAt:   now.Unix(),
+	}
+	return s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "share_id"}, {Name: "client_id"}}, UpdateAll: true}).
+		Create(&m).Error
+}
+
+// ListSharePresence returns the clients seen on a share after since, most recent first.
+func (s *Store) ListSharePresence(ctx context.Context, shareID string, since time.Time) ([]SharePresence, error) {
+	var rows []SharePresenceModel
+	if err := s.db.WithContext(ctx).
+		Where("share_id = ? AND seen_at > ?", strings.TrimSpace(shareID), since.Unix()).
+		Order("seen_at DESC, client_id").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]SharePresence, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, SharePresence{ClientID: r.ClientID, UserSub: r.UserSub, Name: r.Name, State: r.State, SeenAt: time.Unix(r.SeenAt, 0)})
+	}
+	return out, nil
+}
+
+// CleanupExpiredPresence removes presence entries last seen at or before the given time.
+func (s *Store) CleanupExpiredPresence(ctx context.Context, before time.Time) {
+	_ = s.db.WithContext(ctx).Where("seen_at <= ?", before.Unix()).Delete(&SharePresenceModel{}).Error
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Review states of a share. A draft is submitted for review and then approved or rejected; a
+// rejected share can be submitted again, and editing an approved share makes it a draft.
+const (
+	ReviewDraft     = "draft"
+	ReviewSubmitted = "submitted"
+	ReviewApproved  = "approved"
+	ReviewRejected  = "rejected"
+)
+
+// ShareReview is the review state of a share: who moved it to Status and when, the version that
+// was submitted or approved, and an optional note (e.g. why it was rejected). BySub and At are
+// empty for shares that never left draft.
+type ShareReview struct {
+	Status    string
+	BySub     string
+	At        time.Time
+	VersionID string
+	Note      string
+}
+
+// ErrReviewTransition is returned when a share's review state does not allow the requested change.
+var ErrReviewTransition = errors.New("review state does not allow this change")
+
+func reviewStatus(s string) string {
+	if s == "" {
+		return ReviewDraft
+	}
+	return s
+}
+
+func shareReviewFromModel(m ShareModel) ShareReview {
+	r := ShareReview{Status: reviewStatus(m.ReviewStatus), BySub: m.ReviewBySub, VersionID: m.ReviewVersionID, Note: m.ReviewNote}
+	if m.ReviewAt > 0 {
+		r.At = time.Unix(m.ReviewAt, 0)
+	}
+	return r
+}
+
+// SetShareReview moves a share from one of the states in from to r.Status. r.VersionID must be the
+// share's current version, and to approve or reject also the version that was submitted:
+// ErrVersionMismatch is returned otherwise, ErrReviewTransition when the share is not in one of the
+// from states and ErrNotFound when it does not exist.
+func (s *Store) SetShareReview(ctx context.Context, shareID string, from []string, r ShareReview, now time.Time) (ShareReview, error) {
+	shareID = strings.TrimSpace(shareID)
+	r.VersionID = strings.TrimSpace(r.VersionID)
+	r.BySub = strings.TrimSpace(r.BySub)
+	r.Note = strings.TrimSpace(r.Note)
+	r.At = time.Unix(now.Unix(), 0)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var m ShareModel
+		if err := tx.Select("id", "head_version_id", "review_status", "review_version_id").Take(&m, "id = ?", shareID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		allowed := false
+		for _, f := range from {
+			if reviewStatus(m.ReviewStatus) == f {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return ErrReviewTransition
+		}
+		if m.HeadVersionID != r.VersionID {
+			return ErrVersionMismatch
+		}
+		if (r.Status == ReviewApproved || r.Status == ReviewRejected) && m.ReviewVersionID != r.VersionID {
+			return ErrVersionMismatch
+		}
+		res := tx.Model(&ShareModel{}).
+			Where("id = ? AND head_version_id = ? AND review_status = ?", shareID, r.VersionID, m.ReviewStatus).
+			Updates(map[string]any{
+				"review_status":     r.Status,
+				"review_by_sub":     r.BySub,
+				"review_at":         now.Unix(),
+				"review_version_id": r.VersionID,
+				"review_note":       r.Note,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// Changed concurrently.
+			return ErrVersionMismatch
+		}
+		return nil
+	})
+	if err != nil {
+		return ShareReview{}, err
+	}
+	return r, nil
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"eendraadschema-share-server/internal/config"
+	"eendraadschema-share-server/internal/schema"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
@@ -34,6 +35,9 @@ type Store struct {
 	sqlDB *sql.DB
 }
 
+// Driver returns the database driver in use, "sqlite" or "postgres".
+func (s *Store) Driver() string { return s.db.Dialector.Name() }
+
 func Open(cfg config.Config) (*Store, error) {
 	driver := strings.ToLower(strings.TrimSpace(cfg.DBDriver))
 	if driver == "" {
@@ -76,6 +80,8 @@ func Open(cfg config.Config) (*Store, error) {
 		_ = sqlDB.Close()
 		return nil, err
 	}
+	// Best-effort: sweep blobs a crash or an interrupted prune left behind.
+	_, _ = st.CollectSchemaBlobs(context.Background(), time.Now())
 	return st, nil
 }
 
@@ -113,27 +119,66 @@ func (s *Store) UpsertOIDCUser(ctx context.Context, sub string, email string, na
 }
 
 type ShareModel struct {
-	ID       string         `gorm:"column:id;primaryKey"`
-	Name     string         `gorm:"column:name"`
-	Schema   string         `gorm:"column:schema;not null"`
-	OwnerSub string         `gorm:"column:owner_sub;index"`
-	TeamID   sql.NullString `gorm:"column:team_id;index"`
-	CreatedAt int64         `gorm:"column:created_at;not null"`
-	UpdatedAt int64         `gorm:"column:updated_at;not null"`
+	ID     string `gorm:"column:id;primaryKey"`
+	Name   string `gorm:"column:name"`
+	Schema string `gorm:"column:schema;not null"`
+	// SchemaHash refers to the schema in schema_blobs; Schema is only set on rows from before blobs.
+	SchemaHash string         `gorm:"column:schema_hash;not null;default:'';index"`
+	OwnerSub   string         `gorm:"column:owner_sub;index"`
+	TeamID     sql.NullString `gorm:"column:team_id;index"`
+	CreatedAt  int64          `gorm:"column:created_at;not null"`
+	UpdatedAt  int64          `gorm:"column:updated_at;not null"`
+	// HeadVersionID is the share_versions row holding the current schema. It changes in the same
+	// statement as the schema, which makes it usable for optimistic concurrency.
+	HeadVersionID string `gorm:"column:head_version_id;not null;default:''"`
+	// Review state (see ShareReview); rows from before it are drafts.
+	ReviewStatus    string `gorm:"column:review_status;not null;default:'draft';index"`
+	ReviewBySub     string `gorm:"column:review_by_sub;not null;default:''"`
+	ReviewAt        int64  `gorm:"column:review_at;not null;default:0"`
+	ReviewVersionID string `gorm:"column:review_version_id;not null;default:''"`
+	ReviewNote      string `gorm:"column:review_note;not null;default:''"`
 }
 
 func (ShareModel) TableName() string { return "shares" }
 
 type ShareVersionModel struct {
-	ID         string `gorm:"column:id;primaryKey"`
-	ShareID    string `gorm:"column:share_id;not null;index"`
-	Schema     string `gorm:"column:schema;not null"`
-	CreatedAt  int64  `gorm:"column:created_at;not null;index"`
+	ID           string `gorm:"column:id;primaryKey"`
+	ShareID      string `gorm:"column:share_id;not null;index"`
+	Schema       string `gorm:"column:schema;not null"`
+	SchemaHash   string `gorm:"column:schema_hash;not null;default:'';index"`
+	CreatedAt    int64  `gorm:"column:created_at;not null;index"`
 	CreatedBySub string `gorm:"column:created_by_sub;index"`
+	Summary      string `gorm:"column:summary;not null;default:''"`
+	// Optional message from the author, the version this one was derived from and how it came
+	// to be (one of the VersionKind constants). Rows from before these columns have them empty.
+	Message         string `gorm:"column:message;not null;default:''"`
+	ParentVersionID string `gorm:"column:parent_version_id;not null;default:'';index"`
+	Kind            string `gorm:"column:kind;not null;default:''"`
 }
 
 func (ShareVersionModel) TableName() string { return "share_versions" }
 
+const (
+	VersionKindCreate  = "create"
+	VersionKindUpdate  = "update"
+	VersionKindRestore = "restore"
+	VersionKindImport  = "import"
+	// VersionKindMerge is a version the server merged from two concurrent edits of its parent.
+	VersionKindMerge = "merge"
+)
+
+// ShareThumbnailModel caches the rendered preview of a share. SchemaKey identifies the schema (and
+// renderer) it was drawn from, so a new version simply misses the cache and overwrites the row.
+type ShareThumbnailModel struct {
+	ShareID   string `gorm:"column:share_id;primaryKey"`
+	SchemaKey string `gorm:"column:schema_key;not null"`
+	SVG       []byte `gorm:"column:svg;not null"`
+	PNG       []byte `gorm:"column:png;not null"`
+	CreatedAt int64  `gorm:"column:created_at;not null"`
+}
+
+func (ShareThumbnailModel) TableName() string { return "share_thumbnails" }
+
 type SessionModel struct {
 	Token     string `gorm:"column:token;primaryKey"`
 	ShareID   string `gorm:"column:share_id;not null;index"`
@@ -143,11 +188,25 @@ type SessionModel struct {
 
 func (SessionModel) TableName() string { return "sessions" }
 
+// ShareLeaseModel is an edit lease: a soft lock that lets one editor write a share until it
+// expires. HolderID is the user's subject with OIDC, otherwise an id chosen by the client.
+type ShareLeaseModel struct {
+	ShareID    string `gorm:"column:share_id;primaryKey"`
+	HolderID   string `gorm:"column:holder_id;not null"`
+	HolderName string `gorm:"column:holder_name;not null;default:''"`
+	AcquiredAt int64  `gorm:"column:acquired_at;not null"`
+	ExpiresAt  int64  `gorm:"column:expires_at;not null;index"`
+}
+
+func (ShareLeaseModel) TableName() string { return "share_leases" }
+
 type TeamModel struct {
 	ID       string `gorm:"column:id;primaryKey"`
 	Name     string `gorm:"column:name;not null"`
 	OwnerSub string `gorm:"column:owner_sub;not null"`
 	CreatedAt int64 `gorm:"column:created_at;not null"`
+	// Version retention policy for the team's shares; empty uses the server default.
+	RetentionPolicy string `gorm:"column:retention_policy;not null;default:''"`
 }
 
 func (TeamModel) TableName() string { return "teams" }
@@ -184,45 +243,78 @@ func (s *Store) migrate(ctx context.Context) error {
 	}
 
 	// Ensure base tables exist.
-	if err := s.db.WithContext(ctx).AutoMigrate(&UserModel{}, &ShareModel{}, &ShareVersionModel{}, &SessionModel{}, &TeamModel{}, &TeamMemberModel{}, &TeamInviteModel{}); err != nil {
+	if err := s.db.WithContext(ctx).AutoMigrate(&UserModel{}, &ShareModel{}, &ShareVersionModel{}, &ShareVersionTagModel{}, &ShareCommentModel{}, &SchemaBlobModel{}, &ShareThumbnailModel{}, &SessionModel{}, &ShareLeaseModel{}, &SharePresenceModel{}, &ShareTicketModel{}, &TeamModel{}, &TeamMemberModel{}, &TeamInviteModel{}); err != nil {
 		return err
 	}
-	return nil
+	if err := s.migrateSchemaBlobs(ctx); err != nil {
+		return err
+	}
+	// Point shares from before head versions at their newest version with the same schema.
+	return s.db.WithContext(ctx).Exec(`UPDATE shares SET head_version_id = (
+		SELECT v.id FROM share_versions v WHERE v.share_id = shares.id AND v.schema_hash = shares.schema_hash ORDER BY v.created_at DESC LIMIT 1
+	) WHERE head_version_id = '' AND EXISTS (
+		SELECT 1 FROM share_versions v WHERE v.share_id = shares.id AND v.schema_hash = shares.schema_hash
+	)`).Error
 }
 
 
 
 type Share struct {
-	ID        string
-	Name      string
-	Schema    string
-	OwnerSub  string
-	TeamID    sql.NullString
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID     string
+	Name   string
+	Schema string
+	// SchemaHash is the normalised content hash of Schema (see schema.Hash).
+	SchemaHash string
+	OwnerSub   string
+	TeamID     sql.NullString
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	// HeadVersionID is the id of the current version, "" for shares without one.
+	HeadVersionID string
+	Review        ShareReview
 }
 
 type ShareSummary struct {
-	ID        string
-	Name      string
-	TeamID    sql.NullString
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID           string
+	Name         string
+	TeamID       sql.NullString
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ReviewStatus string
 }
 
 type ShareAdminSummary struct {
-	ID        string
-	Name      string
-	OwnerSub  string
-	TeamID    sql.NullString
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID           string
+	Name         string
+	OwnerSub     string
+	TeamID       sql.NullString
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ReviewStatus string
 }
 
 type ShareVersionSummary struct {
-	ID        string
-	CreatedAt time.Time
-	CreatedBySub string
+	ID              string
+	CreatedAt       time.Time
+	CreatedBySub    string
+	Summary         string
+	Message         string
+	ParentVersionID string
+	Kind            string
+}
+
+// ShareVersionInfo describes a new version. ParentVersionID defaults to the share's previous
+// version; restores set it to the version they restore.
+type ShareVersionInfo struct {
+	ID              string
+	CreatedBySub    string
+	Kind            string
+	Message         string
+	Summary         string
+	ParentVersionID string
+	// Summarize, when set, gives the Summary from the schema the version replaces, which is only
+	// known inside the write ("" for a new share).
+	Summarize func(prev string) string
 }
 
 type Team struct {
@@ -233,9 +325,27 @@ type Team struct {
 }
 
 type TeamWithRole struct {
-	ID   string
-	Name string
-	Role string
+	ID              string
+	Name            string
+	Role            string
+	RetentionPolicy string
+}
+
+type ShareVersionStamp struct {
+	ID        string
+	CreatedAt time.Time
+	Tagged    bool
+	// Anchored is set when an unresolved comment thread was started on the version.
+	Anchored bool
+}
+
+// ShareRetentionTarget is a share with versions, and the retention policy of its team (if any).
+// The head version and the version under review are never pruned.
+type ShareRetentionTarget struct {
+	ShareID         string
+	RetentionPolicy string
+	HeadVersionID   string
+	ReviewVersionID string
 }
 
 type User struct {
@@ -250,6 +360,10 @@ type User struct {
 
 var ErrNotFound = errors.New("not found")
 
+// ErrVersionMismatch is returned by conditional updates when the share's head version is not the
+// expected one.
+var ErrVersionMismatch = errors.New("share version mismatch")
+
 func (s *Store) IsUserAdmin(ctx context.Context, sub string) (bool, error) {
 	sub = strings.TrimSpace(sub)
 	if sub == "" {
@@ -359,58 +473,122 @@ func (s *Store) GetUsersBySubs(ctx context.Context, subs []string) (map[string]U
 	return out, nil
 }
 
-func (s *Store) CreateShare(ctx context.Context, id string, name string, schema string, ownerSub string, teamID *string, now time.Time) error {
+// CreateShare stores a new share together with its first version.
+func (s *Store) CreateShare(ctx context.Context, id string, name string, schema string, ownerSub string, teamID *string, version ShareVersionInfo, now time.Time) error {
 	m := ShareModel{
-		ID:        id,
-		Name:      strings.TrimSpace(name),
-		Schema:    schema,
-		OwnerSub:  strings.TrimSpace(ownerSub),
-		CreatedAt: now.Unix(),
-		UpdatedAt: now.Unix(),
+		ID:            id,
+		Name:          strings.TrimSpace(name),
+		OwnerSub:      strings.TrimSpace(ownerSub),
+		CreatedAt:     now.Unix(),
+		UpdatedAt:     now.Unix(),
+		HeadVersionID: strings.TrimSpace(version.ID),
 	}
 	if teamID != nil && strings.TrimSpace(*teamID) != "" {
 		m.TeamID = sql.NullString{String: strings.TrimSpace(*teamID), Valid: true}
 	}
-	return s.db.WithContext(ctx).Create(&m).Error
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		hash, err := putBlob(tx, schema, now)
+		if err != nil {
+			return err
+		}
+		m.SchemaHash = hash
+		if err := tx.Create(&m).Error; err != nil {
+			return err
+		}
+		if version.Summarize != nil {
+			version.Summary = version.Summarize("")
+		}
+		_, err = addShareVersion(tx, id, "", hash, version, now)
+		return err
+	})
 }
 
-func (s *Store) UpdateShare(ctx context.Context, id string, schema string, now time.Time) error {
-	res := s.db.WithContext(ctx).
-		Model(&ShareModel{}).
-		Where("id = ?", id).
-		Updates(map[string]any{"schema": schema, "updated_at": now.Unix()})
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return ErrNotFound
-	}
-	return nil
+func (s *Store) UpdateShare(ctx context.Context, id string, schema string, version ShareVersionInfo, now time.Time) error {
+	return s.UpdateShareFields(ctx, id, &schema, nil, nil, version, now)
 }
 
-func (s *Store) UpdateShareFields(ctx context.Context, id string, schema *string, name *string, now time.Time) error {
+// UpdateShareFields updates the schema and/or name of a share. A new schema is recorded as
+// version, in the same transaction, and becomes the share's head version unless it repeats the
+// previous one. When ifMatch is set, the update only happens while the share's head version is
+// *ifMatch ("" for shares without one), and ErrVersionMismatch is returned otherwise.
+func (s *Store) UpdateShareFields(ctx context.Context, id string, schema *string, name *string, ifMatch *string, version ShareVersionInfo, now time.Time) error {
 	id = strings.TrimSpace(id)
 	if id == "" {
 		return fmt.Errorf("id is required")
 	}
 	updates := map[string]any{"updated_at": now.Unix()}
-	if schema != nil {
-		updates["schema"] = *schema
-	}
 	if name != nil {
 		updates["name"] = strings.TrimSpace(*name)
 	}
-	res := s.db.WithContext(ctx).
-		Model(&ShareModel{}).
-		Where("id = ?", id).
-		Updates(updates)
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return ErrNotFound
-	}
-	return nil
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old ShareModel
+		if err := tx.Select("schema", "schema_hash", "head_version_id").Take(&old, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if schema != nil {
+			hash, err := putBlob(tx, *schema, now)
+			if err != nil {
+				return err
+			}
+			updates["schema_hash"] = hash
+			updates["schema"] = ""
+			updates["head_version_id"] = strings.TrimSpace(version.ID)
+		}
+		q := tx.Model(&ShareModel{}).Where("id = ?", id)
+		if ifMatch != nil {
+			q = q.Where("head_version_id = ?", strings.TrimSpace(*ifMatch))
+		}
+		res := q.Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			if ifMatch != nil {
+				return ErrVersionMismatch
+			}
+			return ErrNotFound
+		}
+		if schema == nil {
+			return nil
+		}
+		hash := updates["schema_hash"].(string)
+		if version.Summarize != nil {
+			prev, err := blobBody(tx, old.SchemaHash, old.Schema)
+			if err != nil {
+				return err
+			}
+			version.Summary = version.Summarize(prev)
+		}
+		head, err := addShareVersion(tx, id, old.HeadVersionID, hash, version, now)
+		if err != nil {
+			return err
+		}
+		if head != updates["head_version_id"] {
+			if err := tx.Model(&ShareModel{}).Where("id = ?", id).UpdateColumn("head_version_id", head).Error; err != nil {
+				return err
+			}
+		}
+		// Editing a submitted or approved share withdraws it from review: an approval only ever
+		// applies to the version that was submitted.
+		if old.SchemaHash != hash {
+			if err := tx.Model(&ShareModel{}).Where("id = ? AND review_status IN ?", id, []string{ReviewSubmitted, ReviewApproved}).Updates(map[string]any{
+				"review_status":     ReviewDraft,
+				"review_by_sub":     "",
+				"review_at":         now.Unix(),
+				"review_version_id": "",
+				"review_note":       "",
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if old.SchemaHash != "" && old.SchemaHash != hash {
+			return collectBlobs(tx, []string{old.SchemaHash}, now)
+		}
+		return nil
+	})
 }
 
 func (s *Store) DeleteShare(ctx context.Context, id string) error {
@@ -419,13 +597,40 @@ func (s *Store) DeleteShare(ctx context.Context, id string) error {
 			return fmt.Errorf("id is required")
 		}
 		return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// Remember the blobs this share uses so they can be collected afterwards.
+			var hashes []string
+			if err := tx.Model(&ShareVersionModel{}).Where("share_id = ?", id).Distinct().Pluck("schema_hash", &hashes).Error; err != nil {
+				return err
+			}
+			var sh ShareModel
+			if err := tx.Select("schema_hash").Take(&sh, "id = ?", id).Error; err == nil {
+				hashes = append(hashes, sh.SchemaHash)
+			}
 			// Clean up versions and sessions first.
 			if err := tx.Where("share_id = ?", id).Delete(&ShareVersionModel{}).Error; err != nil {
 				return err
 			}
+			if err := tx.Where("share_id = ?", id).Delete(&ShareVersionTagModel{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("share_id = ?", id).Delete(&ShareCommentModel{}).Error; err != nil {
+				return err
+			}
 			if err := tx.Where("share_id = ?", id).Delete(&SessionModel{}).Error; err != nil {
 				return err
 			}
+			if err := tx.Where("share_id = ?", id).Delete(&ShareLeaseModel{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("share_id = ?", id).Delete(&SharePresenceModel{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("share_id = ?", id).Delete(&ShareTicketModel{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("share_id = ?", id).Delete(&ShareThumbnailModel{}).Error; err != nil {
+				return err
+			}
 			res := tx.Delete(&ShareModel{}, "id = ?", id)
 			if res.Error != nil {
 				return res.Error
@@ -433,7 +638,7 @@ func (s *Store) DeleteShare(ctx context.Context, id string) error {
 			if res.RowsAffected == 0 {
 				return ErrNotFound
 			}
-			return nil
+			return collectBlobs(tx, hashes, time.Now())
 		})
 }
 
@@ -445,30 +650,81 @@ func (s *Store) GetShare(ctx context.Context, id string) (Share, error) {
 		}
 		return Share{}, err
 	}
+	body, err := blobBody(s.db.WithContext(ctx), m.SchemaHash, m.Schema)
+	if err != nil {
+		return Share{}, err
+	}
+	if m.SchemaHash == "" {
+		m.SchemaHash = schema.Hash(body)
+	}
 	return Share{
-		ID:        m.ID,
-		Name:      m.Name,
-		Schema:    m.Schema,
-		OwnerSub:  m.OwnerSub,
-		TeamID:    m.TeamID,
-		CreatedAt: time.Unix(m.CreatedAt, 0),
-		UpdatedAt: time.Unix(m.UpdatedAt, 0),
+		ID:            m.ID,
+		Name:          m.Name,
+		Schema:        body,
+		SchemaHash:    m.SchemaHash,
+		OwnerSub:      m.OwnerSub,
+		TeamID:        m.TeamID,
+		CreatedAt:     time.Unix(m.CreatedAt, 0),
+		UpdatedAt:     time.Unix(m.UpdatedAt, 0),
+		HeadVersionID: m.HeadVersionID,
+		Review:        shareReviewFromModel(m),
 	}, nil
 }
 
-func (s *Store) AddShareVersion(ctx context.Context, versionID string, shareID string, schema string, createdBySub string, now time.Time) error {
-	shareID = strings.TrimSpace(shareID)
-	if shareID == "" {
-		return fmt.Errorf("shareID is required")
+// GetShareHeadVersion returns the id of the current version of a share ("" if it has none).
+func (s *Store) GetShareHeadVersion(ctx context.Context, id string) (string, error) {
+	var m ShareModel
+	if err := s.db.WithContext(ctx).Select("head_version_id").Take(&m, "id = ?", strings.TrimSpace(id)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrNotFound
+		}
+		return "", err
 	}
+	return m.HeadVersionID, nil
+}
+
+// addShareVersion records the schema blob hash as a new version of a share and returns the id of
+// the share's head version: the new one, or the previous version (the head, prevHeadID, when it is
+// known) if that has the same content, e.g. for an autosave without edits.
+func addShareVersion(tx *gorm.DB, shareID string, prevHeadID string, hash string, info ShareVersionInfo, now time.Time) (string, error) {
 	m := ShareVersionModel{
-		ID:          strings.TrimSpace(versionID),
-		ShareID:     shareID,
-		Schema:      schema,
-		CreatedAt:   now.Unix(),
-		CreatedBySub: strings.TrimSpace(createdBySub),
+		ID:              strings.TrimSpace(info.ID),
+		ShareID:         shareID,
+		SchemaHash:      hash,
+		CreatedAt:       now.Unix(),
+		CreatedBySub:    strings.TrimSpace(info.CreatedBySub),
+		Summary:         info.Summary,
+		Message:         strings.TrimSpace(info.Message),
+		ParentVersionID: strings.TrimSpace(info.ParentVersionID),
+		Kind:            info.Kind,
+	}
+	if m.ID == "" {
+		return "", fmt.Errorf("version id is required")
+	}
+	var prev ShareVersionModel
+	err := gorm.ErrRecordNotFound
+	if prevHeadID != "" {
+		err = tx.Select("id", "schema_hash").Where("share_id = ? AND id = ?", shareID, prevHeadID).Take(&prev).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = tx.Select("id", "schema_hash").Where("share_id = ?", shareID).Order("created_at DESC, id DESC").Take(&prev).Error
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
 	}
-	return s.db.WithContext(ctx).Create(&m).Error
+	if prev.SchemaHash == hash {
+		return prev.ID, nil
+	}
+	if m.ParentVersionID == "" {
+		m.ParentVersionID = prev.ID
+	}
+	if err := tx.Create(&m).Error; err != nil {
+		return "", err
+	}
+	if prev.SchemaHash == "" {
+		return m.ID, nil
+	}
+	return m.ID, deltifyBlob(tx, shareID, prev.SchemaHash, hash)
 }
 
 func (s *Store) ListShareVersions(ctx context.Context, shareID string, limit int) ([]ShareVersionSummary, error) {
@@ -481,7 +737,7 @@ func (s *Store) ListShareVersions(ctx context.Context, shareID string, limit int
 	}
 	var rows []ShareVersionModel
 	if err := s.db.WithContext(ctx).
-		Select("id", "created_at", "created_by_sub").
+		Select("id", "created_at", "created_by_sub", "summary", "message", "parent_version_id", "kind").
 		Where("share_id = ?", shareID).
 		Order("created_at DESC").
 		Limit(limit).
@@ -490,7 +746,7 @@ func (s *Store) ListShareVersions(ctx context.Context, shareID string, limit int
 	}
 	out := make([]ShareVersionSummary, 0, len(rows))
 	for _, r := range rows {
-		out = append(out, ShareVersionSummary{ID: r.ID, CreatedAt: time.Unix(r.CreatedAt, 0), CreatedBySub: r.CreatedBySub})
+		out = append(out, ShareVersionSummary{ID: r.ID, CreatedAt: time.Unix(r.CreatedAt, 0), CreatedBySub: r.CreatedBySub, Summary: r.Summary, Message: r.Message, ParentVersionID: r.ParentVersionID, Kind: r.Kind})
 	}
 	return out, nil
 }
@@ -508,49 +764,248 @@ func (s *Store) GetShareVersion(ctx context.Context, shareID string, versionID s
 		}
 		return "", err
 	}
-	return m.Schema, nil
+	return blobBody(s.db.WithContext(ctx), m.SchemaHash, m.Schema)
 }
 
-func (s *Store) PruneShareVersions(ctx context.Context, shareID string, keep int) error {
+// ListShareVersionStamps returns the ids and creation times of all versions of a share, newest
+// first (by id within the same second), whether they are tagged and whether an unresolved comment
+// thread is anchored to them.
+func (s *Store) ListShareVersionStamps(ctx context.Context, shareID string) ([]ShareVersionStamp, error) {
+	type row struct {
+		ID        string
+		CreatedAt int64
+		Tagged    bool
+		Anchored  bool
+	}
+	var rows []row
+	if err := s.db.WithContext(ctx).
+		Table("share_versions v").
+		Select("v.id as id, v.created_at as created_at, EXISTS (SELECT 1 FROM share_version_tags t WHERE t.share_id = v.share_id AND t.version_id = v.id) as tagged, "+
+			"EXISTS (SELECT 1 FROM share_comments c WHERE c.share_id = v.share_id AND c.version_id = v.id AND c.id = c.thread_id AND c.resolved_at = 0) as anchored").
+		Where("v.share_id = ?", strings.TrimSpace(shareID)).
+		Order("v.created_at DESC, v.id DESC").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]ShareVersionStamp, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, ShareVersionStamp{ID: r.ID, CreatedAt: time.Unix(r.CreatedAt, 0), Tagged: r.Tagged, Anchored: r.Anchored})
+	}
+	return out, nil
+}
+
+// DeleteShareVersions deletes the given versions of a share, in chunks, and the schema blobs only
+// they used. Tagged versions, versions with an unresolved comment thread, the head and the version
+// under review are skipped.
+func (s *Store) DeleteShareVersions(ctx context.Context, shareID string, ids []string) error {
 	shareID = strings.TrimSpace(shareID)
-	if shareID == "" {
-		return fmt.Errorf("shareID is required")
+	for len(ids) > 0 {
+		chunk := ids[:min(len(ids), 500)]
+		ids = ids[len(chunk):]
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var hashes []string
+			untagged := func(q *gorm.DB) *gorm.DB {
+				return q.Where("share_id = ? AND id IN ?", shareID, chunk).
+					Where("NOT EXISTS (SELECT 1 FROM share_version_tags t WHERE t.share_id = share_versions.share_id AND t.version_id = share_versions.id)").
+					Where("NOT EXISTS (SELECT 1 FROM share_comments c WHERE c.share_id = share_versions.share_id AND c.version_id = share_versions.id AND c.id = c.thread_id AND c.resolved_at = 0)").
+					Where("NOT EXISTS (SELECT 1 FROM shares s WHERE s.id = share_versions.share_id AND (s.head_version_id = share_versions.id OR s.review_version_id = share_versions.id))")
+			}
+			if err := untagged(tx.Model(&ShareVersionModel{})).Pluck("schema_hash", &hashes).Error; err != nil {
+				return err
+			}
+			if err := untagged(tx).Delete(&ShareVersionModel{}).Error; err != nil {
+				return err
+			}
+			return collectBlobs(tx, hashes, time.Now())
+		})
+		if err != nil {
+			return err
+		}
 	}
-	if keep <= 0 {
-		return nil
+	return nil
+}
+
+// ListShareRetentionTargets returns shares that have versions, ordered by id and starting after
+// afterID, with their team's retention policy.
+func (s *Store) ListShareRetentionTargets(ctx context.Context, afterID string, limit int) ([]ShareRetentionTarget, error) {
+	var rows []ShareRetentionTarget
+	err := s.db.WithContext(ctx).
+		Table("shares s").
+		Select("s.id as share_id, COALESCE(t.retention_policy, '') as retention_policy, s.head_version_id as head_version_id, s.review_version_id as review_version_id").
+		Joins("LEFT JOIN teams t ON t.id = s.team_id").
+		Where("s.id > ?", afterID).
+		Where("EXISTS (SELECT 1 FROM share_versions v WHERE v.share_id = s.id)").
+		Order("s.id").
+		Limit(limit).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetTeamRetentionPolicy returns the version retention policy of a team; "" means the default.
+func (s *Store) GetTeamRetentionPolicy(ctx context.Context, teamID string) (string, error) {
+	var m TeamModel
+	if err := s.db.WithContext(ctx).Select("retention_policy").Take(&m, "id = ?", strings.TrimSpace(teamID)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrNotFound
+		}
+		return "", err
 	}
-	// Delete everything beyond the newest `keep` versions, in chunks.
+	return m.RetentionPolicy, nil
+}
+
+// SetTeamRetentionPolicy stores the version retention policy of a team; "" restores the default.
+func (s *Store) SetTeamRetentionPolicy(ctx context.Context, teamID string, policy string) error {
+	res := s.db.WithContext(ctx).Model(&TeamModel{}).
+		Where("id = ?", strings.TrimSpace(teamID)).
+		UpdateColumn("retention_policy", policy)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type SchemaRewriteStats struct {
+	Scanned   int
+	Rewritten int
+	Failed    int
+}
+
+type SchemaRewriteFailure struct {
+	Table string
+	ID    string
+	Err   error
+}
+
+// SchemaRewriteResult counts the blobs that were rewritten, and the shares and versions that refer
+// to them.
+type SchemaRewriteResult struct {
+	Blobs    SchemaRewriteStats
+	Shares   int
+	Versions int
+	Failures []SchemaRewriteFailure
+}
+
+// RewriteSchemas calls fn for every stored schema blob that does not start with one of
+// skipPrefixes, and stores the returned schema when fn reports a change (unless dryRun is set).
+// The shares and versions that used the old blob are moved to the new one; share updated_at is
+// left untouched. Blobs are processed in hash order and in chunks.
+func (s *Store) RewriteSchemas(ctx context.Context, skipPrefixes []string, dryRun bool, fn func(schema string) (string, bool, error)) (SchemaRewriteResult, error) {
+	var res SchemaRewriteResult
+	lastHash := ""
 	for {
-		var oldRows []ShareVersionModel
-		if err := s.db.WithContext(ctx).
-			Select("id").
-			Where("share_id = ?", shareID).
-			Order("created_at DESC").
-			Offset(keep).
-			Limit(500).
-			Find(&oldRows).Error; err != nil {
-			return err
+		var rows []SchemaBlobModel
+		q := s.db.WithContext(ctx).Select("hash", "body", "base_hash").Where("hash > ?", lastHash)
+		for _, p := range skipPrefixes {
+			// Deltas have no body; they are rebuilt and checked below.
+			q = q.Where("(body NOT LIKE ? OR base_hash <> '')", p+"%")
 		}
-		if len(oldRows) == 0 {
-			return nil
+		if err := q.Order("hash").Limit(100).Find(&rows).Error; err != nil {
+			return res, err
 		}
-		ids := make([]string, 0, len(oldRows))
-		for _, r := range oldRows {
-			ids = append(ids, r.ID)
+		if len(rows) == 0 {
+			return res, nil
 		}
-		if err := s.db.WithContext(ctx).Where("id IN ?", ids).Delete(&ShareVersionModel{}).Error; err != nil {
-			return err
+		for _, r := range rows {
+			lastHash = r.Hash
+			var err error
+			if r.BaseHash != "" {
+				r.Body, err = blobBody(s.db.WithContext(ctx), r.Hash, "")
+				if err == nil && hasAnyPrefix(r.Body, skipPrefixes) {
+					continue
+				}
+			}
+			res.Blobs.Scanned++
+			var out string
+			var changed bool
+			if err == nil {
+				out, changed, err = fn(r.Body)
+			}
+			if err != nil {
+				res.Blobs.Failed++
+				if len(res.Failures) < 100 {
+					res.Failures = append(res.Failures, SchemaRewriteFailure{Table: SchemaBlobModel{}.TableName(), ID: r.Hash, Err: err})
+				}
+				continue
+			}
+			if !changed {
+				continue
+			}
+			var shares, versions int64
+			err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if dryRun {
+					if err := tx.Model(&ShareModel{}).Where("schema_hash = ?", r.Hash).Count(&shares).Error; err != nil {
+						return err
+					}
+					return tx.Model(&ShareVersionModel{}).Where("schema_hash = ?", r.Hash).Count(&versions).Error
+				}
+				now := time.Now()
+				hash, err := putBlob(tx, out, now)
+				if err != nil {
+					return err
+				}
+				if hash == r.Hash {
+					return nil
+				}
+				upd := tx.Model(&ShareModel{}).Where("schema_hash = ?", r.Hash).UpdateColumn("schema_hash", hash)
+				if upd.Error != nil {
+					return upd.Error
+				}
+				shares = upd.RowsAffected
+				upd = tx.Model(&ShareVersionModel{}).Where("schema_hash = ?", r.Hash).UpdateColumn("schema_hash", hash)
+				if upd.Error != nil {
+					return upd.Error
+				}
+				versions = upd.RowsAffected
+				// Like any unreferenced blob, the old one is only collected once the grace period has
+				// passed since it was last touched; until then the blob collection job leaves it.
+				return collectBlobs(tx, []string{r.Hash}, now)
+			})
+			if err != nil {
+				return res, err
+			}
+			res.Blobs.Rewritten++
+			res.Shares += int(shares)
+			res.Versions += int(versions)
 		}
 	}
 }
 
+type ShareThumbnail struct {
+	SVG []byte
+	PNG []byte
+}
+
+// GetShareThumbnail returns the cached thumbnail of a share, or ErrNotFound when there is none for key.
+func (s *Store) GetShareThumbnail(ctx context.Context, shareID string, key string) (ShareThumbnail, error) {
+	var m ShareThumbnailModel
+	err := s.db.WithContext(ctx).Where("share_id = ? AND schema_key = ?", strings.TrimSpace(shareID), key).Take(&m).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ShareThumbnail{}, ErrNotFound
+		}
+		return ShareThumbnail{}, err
+	}
+	return ShareThumbnail{SVG: m.SVG, PNG: m.PNG}, nil
+}
+
+// PutShareThumbnail stores the thumbnail of a share, replacing the one of an earlier version.
+func (s *Store) PutShareThumbnail(ctx context.Context, shareID string, key string, t ShareThumbnail, now time.Time) error {
+	m := ShareThumbnailModel{ShareID: strings.TrimSpace(shareID), SchemaKey: key, SVG: t.SVG, PNG: t.PNG, CreatedAt: now.Unix()}
+	return s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "share_id"}}, UpdateAll: true}).
+		Create(&m).Error
+}
+
 func (s *Store) ListSharesByOwner(ctx context.Context, ownerSub string, limit int) ([]ShareSummary, error) {
 	if limit <= 0 || limit > 200 {
 		limit = 200
 	}
 	var rows []ShareModel
 	if err := s.db.WithContext(ctx).
-		Select("id", "name", "team_id", "created_at", "updated_at").
+		Select("id", "name", "team_id", "created_at", "updated_at", "review_status").
 		Where("owner_sub = ?", strings.TrimSpace(ownerSub)).
 		Order("updated_at DESC").
 		Limit(limit).
@@ -559,7 +1014,7 @@ func (s *Store) ListSharesByOwner(ctx context.Context, ownerSub string, limit in
 	}
 	out := make([]ShareSummary, 0, len(rows))
 	for _, r := range rows {
-		out = append(out, ShareSummary{ID: r.ID, Name: r.Name, TeamID: r.TeamID, CreatedAt: time.Unix(r.CreatedAt, 0), UpdatedAt: time.Unix(r.UpdatedAt, 0)})
+		out = append(out, ShareSummary{ID: r.ID, Name: r.Name, TeamID: r.TeamID, CreatedAt: time.Unix(r.CreatedAt, 0), UpdatedAt: time.Unix(r.UpdatedAt, 0), ReviewStatus: reviewStatus(r.ReviewStatus)})
 	}
 	return out, nil
 }
@@ -575,7 +1030,7 @@ func (s *Store) ListAllShares(ctx context.Context, limit int) ([]ShareAdminSumma
 	var rows []ShareModel
 	if err := s.db.WithContext(ctx).
 		Model(&ShareModel{}).
-		Select("id", "name", "owner_sub", "team_id", "created_at", "updated_at").
+		Select("id", "name", "owner_sub", "team_id", "created_at", "updated_at", "review_status").
 		Order("updated_at desc").
 		Limit(limit).
 		Find(&rows).Error; err != nil {
@@ -584,12 +1039,13 @@ func (s *Store) ListAllShares(ctx context.Context, limit int) ([]ShareAdminSumma
 	out := make([]ShareAdminSummary, 0, len(rows))
 	for _, r := range rows {
 		out = append(out, ShareAdminSummary{
-			ID:        r.ID,
-			Name:      r.Name,
-			OwnerSub:  r.OwnerSub,
-			TeamID:    r.TeamID,
-			CreatedAt: time.Unix(r.CreatedAt, 0),
-			UpdatedAt: time.Unix(r.UpdatedAt, 0),
+			ID:           r.ID,
+			Name:         r.Name,
+			OwnerSub:     r.OwnerSub,
+			TeamID:       r.TeamID,
+			CreatedAt:    time.Unix(r.CreatedAt, 0),
+			UpdatedAt:    time.Unix(r.UpdatedAt, 0),
+			ReviewStatus: reviewStatus(r.ReviewStatus),
 		})
 	}
 	return out, nil
@@ -618,14 +1074,15 @@ func (s *Store) CreateTeam(ctx context.Context, id string, name string, ownerSub
 func (s *Store) ListTeamsForUser(ctx context.Context, userSub string) ([]TeamWithRole, error) {
 	userSub = strings.TrimSpace(userSub)
 	type row struct {
-		ID   string
-		Name string
-		Role string
+		ID              string
+		Name            string
+		Role            string
+		RetentionPolicy string
 	}
 	var rows []row
 	if err := s.db.WithContext(ctx).
 		Table("team_members m").
-		Select("t.id as id, t.name as name, m.role as role").
+		Select("t.id as id, t.name as name, m.role as role, t.retention_policy as retention_policy").
 		Joins("JOIN teams t ON t.id = m.team_id").
 		Where("m.user_sub = ?", userSub).
 		Order("t.created_at DESC").
@@ -634,7 +1091,7 @@ func (s *Store) ListTeamsForUser(ctx context.Context, userSub string) ([]TeamWit
 	}
 	out := make([]TeamWithRole, 0, len(rows))
 	for _, r := range rows {
-		out = append(out, TeamWithRole{ID: r.ID, Name: r.Name, Role: r.Role})
+		out = append(out, TeamWithRole{ID: r.ID, Name: r.Name, Role: r.Role, RetentionPolicy: r.RetentionPolicy})
 	}
 	return out, nil
 }
@@ -745,6 +1202,102 @@ func (s *Store) CleanupExpiredSessions(ctx context.Context, now time.Time) {
 	_ = s.db.WithContext(ctx).Where("expires_at <= ?", now.Unix()).Delete(&SessionModel{}).Error
 }
 
+type ShareLease struct {
+	ShareID    string
+	HolderID   string
+	HolderName string
+	AcquiredAt time.Time
+	ExpiresAt  time.Time
+}
+
+func shareLeaseFromModel(m ShareLeaseModel) ShareLease {
+	return ShareLease{ShareID: m.ShareID, HolderID: m.HolderID, HolderName: m.HolderName, AcquiredAt: time.Unix(m.AcquiredAt, 0), ExpiresAt: time.Unix(m.ExpiresAt, 0)}
+}
+
+// ErrLeaseHeld is returned when another holder has an unexpired lease on the share.
+var ErrLeaseHeld = errors.New("share is leased by another editor")
+
+// AcquireShareLease grants or renews the edit lease of a share for holderID until expiresAt. When
+// someone else holds an unexpired lease it returns that lease and ErrLeaseHeld, unless force is
+// set, in which case the lease is taken over.
+func (s *Store) AcquireShareLease(ctx context.Context, shareID string, holderID string, holderName string, force bool, expiresAt time.Time, now time.Time) (ShareLease, error) {
+	shareID = strings.TrimSpace(shareID)
+	holderID = strings.TrimSpace(holderID)
+	if shareID == "" || holderID == "" {
+		return ShareLease{}, fmt.Errorf("shareID and holderID are required")
+	}
+	var out ShareLease
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("share_id = ? AND expires_at <= ?", shareID, now.Unix()).Delete(&ShareLeaseModel{}).Error; err != nil {
+			return err
+		}
+		m := ShareLeaseModel{ShareID: shareID, HolderID: holderID, HolderName: strings.TrimSpace(holderName), AcquiredAt: now.Unix(), ExpiresAt: expiresAt.Unix()}
+		res := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "share_id"}}, DoNothing: true}).Create(&m)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 1 {
+			out = shareLeaseFromModel(m)
+			return nil
+		}
+		var cur ShareLeaseModel
+		if err := tx.Take(&cur, "share_id = ?", shareID).Error; err != nil {
+			return err
+		}
+		if cur.HolderID != holderID && !force {
+			out = shareLeaseFromModel(cur)
+			return ErrLeaseHeld
+		}
+		// A renewal keeps the original acquisition time.
+		if cur.HolderID == holderID {
+			m.AcquiredAt = cur.AcquiredAt
+		}
+		if err := tx.Model(&ShareLeaseModel{}).Where("share_id = ?", shareID).Updates(map[string]any{
+			"holder_id":   m.HolderID,
+			"holder_name": m.HolderName,
+			"acquired_at": m.AcquiredAt,
+			"expires_at":  m.ExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+		out = shareLeaseFromModel(m)
+		return nil
+	})
+	if err != nil && err != ErrLeaseHeld {
+		return ShareLease{}, err
+	}
+	return out, err
+}
+
+// GetShareLease returns the unexpired edit lease of a share, or ErrNotFound.
+func (s *Store) GetShareLease(ctx context.Context, shareID string, now time.Time) (ShareLease, error) {
+	var m ShareLeaseModel
+	if err := s.db.WithContext(ctx).Take(&m, "share_id = ? AND expires_at > ?", strings.TrimSpace(shareID), now.Unix()).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ShareLease{}, ErrNotFound
+		}
+		return ShareLease{}, err
+	}
+	return shareLeaseFromModel(m), nil
+}
+
+// ReleaseShareLease ends the lease of a share if holderID holds it. It returns ErrNotFound
+// otherwise.
+func (s *Store) ReleaseShareLease(ctx context.Context, shareID string, holderID string) error {
+	res := s.db.WithContext(ctx).Where("share_id = ? AND holder_id = ?", strings.TrimSpace(shareID), strings.TrimSpace(holderID)).Delete(&ShareLeaseModel{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) CleanupExpiredLeases(ctx context.Context, now time.Time) {
+	_ = s.db.WithContext(ctx).Where("expires_at <= ?", now.Unix()).Delete(&ShareLeaseModel{}).Error
+}
+
 func (s *Store) HealthCheck(ctx context.Context) error {
 	if err := s.sqlDB.PingContext(ctx); err != nil {
 		return fmt.Errorf("db ping failed: %w", err)
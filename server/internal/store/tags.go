@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShareVersionTagModel names a version of a share, e.g. "offerte" or "as-built". Names are unique
+// per share, so a tag identifies one version. Tagged versions are never pruned.
+type ShareVersionTagModel struct {
+	ShareID      string `gorm:"column:share_id;primaryKey"`
+	Name         string `gorm:"column:name;primaryKey"`
+	VersionID    string `gorm:"column:version_id;not null;index"`
+	CreatedBySub string `gorm:"column:created_by_sub"`
+	CreatedAt    int64  `gorm:"column:created_at;not null"`
+}
+
+func (ShareVersionTagModel) TableName() string { return "share_version_tags" }
+
+type ShareVersionTag struct {
+	Name         string
+	VersionID    string
+	CreatedBySub string
+	CreatedAt    time.Time
+}
+
+// ErrTagExists is returned when a share already has a tag with the requested name.
+var ErrTagExists = errors.New("tag already exists")
+
+// AddShareVersionTag tags a version of a share. It returns ErrNotFound when the version does not
+// exist and ErrTagExists when the name is already used on the share.
+func (s *Store) AddShareVersionTag(ctx context.Context, shareID string, versionID string, name string, createdBySub string, now time.Time) error {
+	shareID = strings.TrimSpace(shareID)
+	versionID = strings.TrimSpace(versionID)
+	if shareID == "" || versionID == "" || name == "" {
+		return fmt.Errorf("shareID, versionID and name are required")
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&ShareVersionModel{}).Where("share_id = ? AND id = ?", shareID, versionID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		// The insert decides, so two requests for the same name cannot both succeed.
+		res := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "share_id"}, {Name: "name"}}, DoNothing: true}).Create(&ShareVersionTagModel{
+			ShareID:      shareID,
+			Name:         name,
+			VersionID:    versionID,
+			CreatedBySub: strings.TrimSpace(createdBySub),
+			CreatedAt:    now.Unix(),
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrTagExists
+		}
+		return nil
+	})
+}
+
+// ListShareVersionTags returns the tags of a share, or of one of its versions when versionID is
+// set, oldest first.
+func (s *Store) ListShareVersionTags(ctx context.Context, shareID string, versionID string) ([]ShareVersionTag, error) {
+	q := s.db.WithContext(ctx).Where("share_id = ?", strings.TrimSpace(shareID))
+	if versionID = strings.TrimSpace(versionID); versionID != "" {
+		q = q.Where("version_id = ?", versionID)
+	}
+	var rows []ShareVersionTagModel
+	if err := q.Order("created_at, name").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]ShareVersionTag, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, ShareVersionTag{Name: r.Name, VersionID: r.VersionID, CreatedBySub: r.CreatedBySub, CreatedAt: time.Unix(r.CreatedAt, 0)})
+	}
+	return out, nil
+}
+
+// DeleteShareVersionTag removes a tag from a version. It returns ErrNotFound when the version
+// has no such tag.
+func (s *Store) DeleteShareVersionTag(ctx context.Context, shareID string, versionID string, name string) error {
+	res := s.db.WithContext(ctx).
+		Where("share_id = ? AND version_id = ? AND name = ?", strings.TrimSpace(shareID), strings.TrimSpace(versionID), name).
+		Delete(&ShareVersionTagModel{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetShareVersionTag returns the tag of a share with the given name, or ErrNotFound.
+func (s *Store) GetShareVersionTag(ctx context.Context, shareID string, name string) (ShareVersionTag, error) {
+	var m ShareVersionTagModel
+	if err := s.db.WithContext(ctx).Take(&m, "share_id = ? AND name = ?", strings.TrimSpace(shareID), name).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ShareVersionTag{}, ErrNotFound
+		}
+		return ShareVersionTag{}, err
+	}
+	return ShareVersionTag{Name: m.Name, VersionID: m.VersionID, CreatedBySub: m.CreatedBySub, CreatedAt: time.Unix(m.CreatedAt, 0)}, nil
+}
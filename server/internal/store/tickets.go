@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ShareTicketModel is a short-lived token granting read access to one share, for the endpoints a
+// browser opens without sending an Authorization header (EventSource, <img>). UserSub is the user
+// it was issued to with OIDC. A ticket without ShareID is for every share that user can read, as
+// handed out with share lists.
+type ShareTicketModel struct {
+	Token     string `gorm:"column:token;primaryKey"`
+	ShareID   string `gorm:"column:share_id;not null;index"`
+	UserSub   string `gorm:"column:user_sub;not null;default:''"`
+	ExpiresAt int64  `gorm:"column:expires_at;not null;index"`
+	CreatedAt int64  `gorm:"column:created_at;not null"`
+}
+
+func (ShareTicketModel) TableName() string { return "share_tickets" }
+
+type ShareTicket struct {
+	// ShareID is "" for a ticket issued to UserSub for all shares they can read.
+	ShareID string
+	UserSub string
+}
+
+// CreateShareTicket stores a ticket for one share, or with an empty shareID for all shares of
+// userSub.
+func (s *Store) CreateShareTicket(ctx context.Context, token string, shareID string, userSub string, expiresAt time.Time, now time.Time) error {
+	shareID = strings.TrimSpace(shareID)
+	userSub = strings.TrimSpace(userSub)
+	if token == "" || (shareID == "" && userSub == "") {
+		return fmt.Errorf("token and shareID or userSub are required")
+	}
+	m := ShareTicketModel{Token: token, ShareID: shareID, UserSub: userSub, ExpiresAt: expiresAt.Unix(), CreatedAt: now.Unix()}
+	return s.db.WithContext(ctx).Create(&m).Error
+}
+
+// GetShareTicket returns a ticket that is valid for the share: one issued for it or one issued
+// to a user for all shares. It returns ErrNotFound when the ticket is unknown, has expired or is
+// for another share.
+func (s *Store) GetShareTicket(ctx context.Context, token string, shareID string, now time.Time) (ShareTicket, error) {
+	var m ShareTicketModel
+	if err := s.db.WithContext(ctx).Take(&m, "token = ? AND share_id IN ?", token, []string{strings.TrimSpace(shareID), ""}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ShareTicket{}, ErrNotFound
+		}
+		return ShareTicket{}, err
+	}
+	if now.Unix() >= m.ExpiresAt {
+		return ShareTicket{}, ErrNotFound
+	}
+	return ShareTicket{ShareID: m.ShareID, UserSub: m.UserSub}, nil
+}
+
+func (s *Store) CleanupExpiredTickets(ctx context.Context, now time.Time) {
+	_ = s.db.WithContext(ctx).Where("expires_at <= ?", now.Unix()).Delete(&ShareTicketModel{}).Error
+}
@@ -0,0 +1,119 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PNG rasterises the drawing. Labels use a built-in 3×5 pixel font with capitals and digits only,
+// which is all that is legible at thumbnail size anyway.
+func (d *Drawing) PNG() ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	for _, s := range d.shapes {
+		switch s.kind {
+		case rectShape:
+			fillRect(img, s.x, s.y, s.w, s.h, parseColor(s.fill))
+			if s.stroke != "" {
+				c, sw := parseColor(s.stroke), s.strokeW
+				fillRect(img, s.x-sw/2, s.y-sw/2, s.w+sw, sw, c)
+				fillRect(img, s.x-sw/2, s.y+s.h-sw/2, s.w+sw, sw, c)
+				fillRect(img, s.x-sw/2, s.y-sw/2, sw, s.h+sw, c)
+				fillRect(img, s.x+s.w-sw/2, s.y-sw/2, sw, s.h+sw, c)
+			}
+		case textShape:
+			drawText(img, s)
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func fillRect(img *image.RGBA, x, y, w, h float64, c color.RGBA) {
+	r := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	// Keep hairlines visible after rounding.
+	if r.Dx() == 0 && w > 0 {
+		r.Max.X++
+	}
+	if r.Dy() == 0 && h > 0 {
+		r.Max.Y++
+	}
+	r = r.Intersect(img.Bounds())
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			img.SetRGBA(px, py, c)
+		}
+	}
+}
+
+// parseColor reads the #rrggbb colours used by the drawing; anything else is black.
+func parseColor(s string) color.RGBA {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(s) != 7 {
+		return color.RGBA{A: 255}
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}
+}
+
+func drawText(img *image.RGBA, s shape) {
+	scale := max(1, int(math.Round(s.size/5)))
+	if s.size < 10 {
+		scale = 1
+	}
+	text := []rune(strings.ToUpper(s.text))
+	advance := 4 * scale
+	width := len(text)*advance - scale
+	x := int(math.Round(s.x))
+	if !s.start {
+		x -= width / 2
+	}
+	top := int(math.Round(s.y)) - 5*scale
+	c := parseColor(s.fill)
+	for _, r := range text {
+		if f, ok := glyphFold[r]; ok {
+			r = f
+		}
+		g, ok := glyphs[r]
+		if !ok && !unicode.IsSpace(r) {
+			g = glyphs['?']
+		}
+		for row := 0; row < 5; row++ {
+			for col := 0; col < 3; col++ {
+				if g[row]&(4>>col) != 0 {
+					fillRect(img, float64(x+col*scale), float64(top+row*scale), float64(scale), float64(scale), c)
+				}
+			}
+		}
+		x += advance
+	}
+}
+
+// glyphs holds 3×5 bitmaps, one row per byte with the left pixel in bit 2.
+var glyphs = map[rune][5]uint8{
+	'A': {2, 5, 7, 5, 5}, 'B': {6, 5, 6, 5, 6}, 'C': {3, 4, 4, 4, 3}, 'D': {6, 5, 5, 5, 6},
+	'E': {7, 4, 6, 4, 7}, 'F': {7, 4, 6, 4, 4}, 'G': {3, 4, 5, 5, 3}, 'H': {5, 5, 7, 5, 5},
+	'I': {7, 2, 2, 2, 7}, 'J': {1, 1, 1, 5, 2}, 'K': {5, 5, 6, 5, 5}, 'L': {4, 4, 4, 4, 7},
+	'M': {5, 7, 7, 5, 5}, 'N': {6, 5, 5, 5, 5}, 'O': {2, 5, 5, 5, 2}, 'P': {6, 5, 6, 4, 4},
+	'Q': {2, 5, 5, 6, 3}, 'R': {6, 5, 6, 5, 5}, 'S': {3, 4, 2, 1, 6}, 'T': {7, 2, 2, 2, 2},
+	'U': {5, 5, 5, 5, 7}, 'V': {5, 5, 5, 5, 2}, 'W': {5, 5, 7, 7, 5}, 'X': {5, 5, 2, 5, 5},
+	'Y': {5, 5, 2, 2, 2}, 'Z': {7, 1, 2, 4, 7},
+	'0': {7, 5, 5, 5, 7}, '1': {2, 6, 2, 2, 7}, '2': {6, 1, 2, 4, 7}, '3': {6, 1, 2, 1, 6},
+	'4': {5, 5, 7, 1, 1}, '5': {7, 4, 6, 1, 6}, '6': {3, 4, 7, 5, 7}, '7': {7, 1, 2, 2, 2},
+	'8': {7, 5, 7, 5, 7}, '9': {7, 5, 7, 1, 6},
+	'-': {0, 0, 7, 0, 0}, '.': {0, 0, 0, 0, 2}, '/': {1, 1, 2, 4, 4}, '+': {0, 2, 7, 2, 0},
+	'?': {6, 1, 2, 0, 2},
+}
+
+// glyphFold draws accented capitals as their base letter.
+var glyphFold = map[rune]rune{
+	'À': 'A', 'Á': 'A', 'Â': 'A', 'Ä': 'A', 'Ç': 'C', 'È': 'E', 'É': 'E', 'Ê': 'E', 'Ë': 'E',
+	'Î': 'I', 'Ï': 'I', 'Ô': 'O', 'Ö': 'O', 'Û': 'U', 'Ü': 'U',
+}
@@ -0,0 +1,38 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// SVG returns the drawing as a standalone SVG document.
+func (d *Drawing) SVG() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, Width, Height, Width, Height)
+	b.WriteString("\n")
+	for _, s := range d.shapes {
+		switch s.kind {
+		case rectShape:
+			fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"`, svgNum(s.x), svgNum(s.y), svgNum(s.w), svgNum(s.h), s.fill)
+			if s.stroke != "" {
+				fmt.Fprintf(&b, ` stroke="%s" stroke-width="%s"`, s.stroke, svgNum(s.strokeW))
+			}
+			b.WriteString("/>\n")
+		case textShape:
+			anchor := "middle"
+			if s.start {
+				anchor = "start"
+			}
+			fmt.Fprintf(&b, `<text x="%s" y="%s" font-family="sans-serif" font-size="%s" fill="%s" text-anchor="%s">`, svgNum(s.x), svgNum(s.y), svgNum(s.size), s.fill, anchor)
+			_ = xml.EscapeText(&b, []byte(s.text))
+			b.WriteString("</text>\n")
+		}
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+func svgNum(f float64) string { return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64) }
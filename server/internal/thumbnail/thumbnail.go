@@ -0,0 +1,252 @@
+// Package thumbnail draws a small one-line diagram of a schema for list views: the distribution
+// board with its first level of kringen. The same drawing is written as SVG or rasterised to PNG
+// without any third-party dependency.
+package thumbnail
+
+import (
+	"fmt"
+	"strings"
+
+	"eendraadschema-share-server/internal/schema"
+)
+
+// Revision changes whenever the drawing changes, so cached thumbnails are redrawn.
+const Revision = 1
+
+const (
+	Width  = 320
+	Height = 160
+
+	pad        = 12.0
+	busY       = 28.0
+	labelY     = 120.0
+	inkColor   = "#1f2933"
+	mutedColor = "#7b8794"
+)
+
+// Fill colours of the protection symbol per bescherming.
+var protectionFill = map[string]string{
+	"differentieel":         "#cfe3ff",
+	"differentieelautomaat": "#cfe3ff",
+	"smelt":                 "#ffe3b3",
+	"geen":                  "#eeeeee",
+}
+
+type shapeKind int
+
+const (
+	rectShape shapeKind = iota
+	textShape
+)
+
+type shape struct {
+	kind       shapeKind
+	x, y, w, h float64
+	fill       string
+	stroke     string
+	strokeW    float64
+	// Text is drawn with its baseline at y, centred on x unless start is set.
+	text  string
+	size  float64
+	start bool
+}
+
+// Drawing is a rendered thumbnail made of axis-aligned rectangles and short labels.
+type Drawing struct {
+	shapes []shape
+}
+
+func (d *Drawing) rect(x, y, w, h float64, fill string) {
+	d.shapes = append(d.shapes, shape{kind: rectShape, x: x, y: y, w: w, h: h, fill: fill})
+}
+
+func (d *Drawing) box(x, y, w, h float64, fill string) {
+	d.shapes = append(d.shapes, shape{kind: rectShape, x: x, y: y, w: w, h: h, fill: fill, stroke: inkColor, strokeW: 1.5})
+}
+
+func (d *Drawing) vline(x, y1, y2 float64) { d.rect(x-0.75, y1, 1.5, y2-y1, inkColor) }
+
+func (d *Drawing) hline(x1, x2, y, width float64) { d.rect(x1, y-width/2, x2-x1, width, inkColor) }
+
+func (d *Drawing) label(x, y, size float64, fill, s string, start bool) {
+	if s == "" {
+		return
+	}
+	d.shapes = append(d.shapes, shape{kind: textShape, x: x, y: y, text: s, size: size, fill: fill, start: start})
+}
+
+// circuit is a kring on the board; sub holds the kringen it feeds through a sub-board, such as the
+// kringen behind a shared differential.
+type circuit struct {
+	item *schema.Item
+	sub  []*schema.Item
+}
+
+func (c circuit) slots() int { return max(1, len(c.sub)) }
+
+type board struct {
+	name     string
+	circuits []circuit
+}
+
+// Render lays out the boards of the schema. Boards nested in a kring are not drawn on their own:
+// their kringen appear below the kring that feeds them.
+func Render(doc *schema.Document) *Drawing {
+	boards := topBoards(doc)
+	d := &Drawing{}
+	d.rect(0, 0, Width, Height, "#ffffff")
+
+	total, kringen := 0, 0
+	for _, b := range boards {
+		for _, c := range b.circuits {
+			total += c.slots()
+			kringen += 1 + len(c.sub)
+		}
+	}
+	if total == 0 {
+		d.label(Width/2, Height/2+4, 10, mutedColor, "Geen kringen", false)
+		return d
+	}
+
+	slot := (Width - 2*pad) / float64(total)
+	x := pad
+	for _, b := range boards {
+		n := 0
+		for _, c := range b.circuits {
+			n += c.slots()
+		}
+		if n == 0 {
+			continue
+		}
+		w := slot * float64(n)
+		d.hline(x+2, x+w-2, busY, 4)
+		d.label(x+2, busY-8, 9, inkColor, fitLabel(b.name, w), true)
+		for _, c := range b.circuits {
+			cw := slot * float64(c.slots())
+			drawCircuit(d, c, x, cw)
+			x += cw
+		}
+	}
+	d.label(pad, Height-10, 9, mutedColor, fmt.Sprintf("%d kringen", kringen), true)
+	return d
+}
+
+func drawCircuit(d *Drawing, c circuit, x, w float64) {
+	cx := x + w/2
+	if len(c.sub) == 0 {
+		drawLeaf(d, c.item, cx, w, busY, labelY)
+		return
+	}
+	// A group: the feeding kring, then a small bus with its kringen.
+	bw := min(14, w*0.6)
+	d.vline(cx, busY, 40)
+	d.box(cx-bw/2, 40, bw, 14, fillFor(c.item))
+	d.vline(cx, 54, 66)
+	d.label(cx+bw/2+3, 51, 8, mutedColor, fitLabel(kringName(c.item), w/2-bw/2-3), true)
+	d.hline(x+3, x+w-3, 66, 2.5)
+	sw := w / float64(len(c.sub))
+	for i, k := range c.sub {
+		drawLeaf(d, k, x+sw*(float64(i)+0.5), sw, 66, labelY)
+	}
+}
+
+func drawLeaf(d *Drawing, k *schema.Item, cx, w, top, label float64) {
+	bw := min(14, w*0.6)
+	boxY := top + (label-top)/2 - 16
+	d.vline(cx, top, boxY)
+	d.box(cx-bw/2, boxY, bw, 16, fillFor(k))
+	d.vline(cx, boxY+16, label-14)
+	if w >= 10 {
+		d.label(cx, label, 10, inkColor, fitLabel(kringName(k), w), false)
+	}
+}
+
+func fillFor(k *schema.Item) string {
+	if f, ok := protectionFill[strings.TrimSpace(k.Props.String("bescherming"))]; ok {
+		return f
+	}
+	return "#ffffff"
+}
+
+func kringName(k *schema.Item) string {
+	n := strings.TrimSpace(k.Props.String("naam"))
+	if n == "---" {
+		return ""
+	}
+	return n
+}
+
+// fitLabel cuts s to the number of characters that fit in w at the label size.
+func fitLabel(s string, w float64) string {
+	n := int(w / 6.5)
+	r := []rune(s)
+	if n <= 0 {
+		return ""
+	}
+	if len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// topBoards returns the boards that are not inside another board, or a single unnamed board with
+// the root kringen when the schema has no board at all.
+func topBoards(doc *schema.Document) []board {
+	var out []board
+	for i := range doc.Data {
+		it := &doc.Data[i]
+		if it.Type() != "Bord" || !doc.Attached(it.ID) || insideBoard(doc, it) {
+			continue
+		}
+		b := board{name: strings.TrimSpace(it.Props.String("naam"))}
+		for _, k := range kringenBelow(doc, it) {
+			b.circuits = append(b.circuits, circuit{item: k, sub: subCircuits(doc, k)})
+		}
+		out = append(out, b)
+	}
+	if len(out) > 0 {
+		return out
+	}
+	b := board{}
+	for i := range doc.Data {
+		it := &doc.Data[i]
+		if it.Type() == "Kring" && doc.Attached(it.ID) && doc.KringOf(it) == nil {
+			b.circuits = append(b.circuits, circuit{item: it, sub: subCircuits(doc, it)})
+		}
+	}
+	return []board{b}
+}
+
+func insideBoard(doc *schema.Document, it *schema.Item) bool {
+	for depth, p := 0, doc.ParentOf(it); p != nil && depth < len(doc.Data); depth, p = depth+1, doc.ParentOf(p) {
+		if p.Type() == "Bord" {
+			return true
+		}
+	}
+	return false
+}
+
+// kringenBelow returns the kringen directly on a board.
+func kringenBelow(doc *schema.Document, b *schema.Item) []*schema.Item {
+	var out []*schema.Item
+	for _, c := range doc.Children(b.ID) {
+		if c.Type() == "Kring" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// subCircuits returns the kringen a kring feeds, directly or through a sub-board.
+func subCircuits(doc *schema.Document, k *schema.Item) []*schema.Item {
+	var out []*schema.Item
+	for _, c := range doc.Children(k.ID) {
+		switch c.Type() {
+		case "Kring":
+			out = append(out, c)
+		case "Bord":
+			out = append(out, kringenBelow(doc, c)...)
+		}
+	}
+	return out
+}